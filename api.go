@@ -0,0 +1,547 @@
+package zapdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const defaultAPIEndpoint = "https://logging.googleapis.com/v2/entries:write"
+
+// apiConfig is used to configure the Cloud Logging API core.
+type apiConfig struct {
+	// Endpoint is the URL of the Cloud Logging `entries.write` method.
+	Endpoint string
+
+	// Client is used to send requests to the endpoint. It is expected to
+	// authenticate the requests, for example by using an OAuth2 transport.
+	Client *http.Client
+
+	// LevelEnabler decides which entries are sent to the API.
+	LevelEnabler zapcore.LevelEnabler
+
+	// BatchCount is the maximum number of entries sent in a single request.
+	BatchCount int
+
+	// BatchBytes is the maximum size in bytes of the entries sent in a single
+	// request.
+	BatchBytes int
+
+	// FlushInterval is the maximum amount of time an entry is buffered before
+	// it is sent to the API.
+	FlushInterval time.Duration
+
+	// MaxRetries is the number of times a failed request is retried.
+	MaxRetries int
+
+	// Backoff is the time waited before the first retry. It is doubled for
+	// every subsequent retry.
+	Backoff time.Duration
+
+	// Resource is the monitored resource the entries are written for.
+	Resource *Resource
+
+	// AuditLogName is the log that entries created with `Audit` are written
+	// to. If empty, they are written to the same log as all other entries.
+	AuditLogName string
+}
+
+// APIEndpoint sets the URL of the Cloud Logging `entries.write` method. It
+// defaults to the public Cloud Logging API.
+func APIEndpoint(endpoint string) func(*apiConfig) {
+	return func(c *apiConfig) {
+		c.Endpoint = endpoint
+	}
+}
+
+// APIHTTPClient sets the HTTP client used to send entries to Cloud Logging.
+// The client is responsible for authenticating the requests.
+func APIHTTPClient(client *http.Client) func(*apiConfig) {
+	return func(c *apiConfig) {
+		c.Client = client
+	}
+}
+
+// APILevel sets the minimum level of entries sent to Cloud Logging. It defaults
+// to InfoLevel.
+func APILevel(enab zapcore.LevelEnabler) func(*apiConfig) {
+	return func(c *apiConfig) {
+		c.LevelEnabler = enab
+	}
+}
+
+// APIBatch sets the maximum number of entries and bytes sent to Cloud Logging
+// in a single request. Values of zero or less are ignored.
+func APIBatch(count, bytes int) func(*apiConfig) {
+	return func(c *apiConfig) {
+		c.BatchCount = count
+		c.BatchBytes = bytes
+	}
+}
+
+// APIFlushInterval sets the maximum amount of time an entry is buffered before
+// it is sent to Cloud Logging. Intervals of zero or less are ignored.
+func APIFlushInterval(interval time.Duration) func(*apiConfig) {
+	return func(c *apiConfig) {
+		c.FlushInterval = interval
+	}
+}
+
+// APIRetries sets the number of times a failed request to Cloud Logging is
+// retried, and the time waited before the first retry. The wait time is
+// doubled for every subsequent retry.
+func APIRetries(max int, backoff time.Duration) func(*apiConfig) {
+	return func(c *apiConfig) {
+		c.MaxRetries = max
+		c.Backoff = backoff
+	}
+}
+
+// APIResource sets the monitored resource the entries are written for. It
+// defaults to the "global" resource type. Use `DetectResource` to detect the
+// resource the application runs on.
+func APIResource(resource *Resource) func(*apiConfig) {
+	return func(c *apiConfig) {
+		c.Resource = resource
+	}
+}
+
+// APIAuditLogName sets the log that entries created with `Audit` are written
+// to, such as "audit". By default, they are written to the same log as all
+// other entries.
+func APIAuditLogName(logName string) func(*apiConfig) {
+	return func(c *apiConfig) {
+		c.AuditLogName = logName
+	}
+}
+
+// APICore is a Zap core that sends log entries directly to the Cloud Logging
+// API, instead of writing them to an output stream scraped by a logging agent.
+//
+// The special purpose logging fields of this package are translated into their
+// matching LogEntry fields. All other fields end up in the `jsonPayload`.
+//
+// Entries are sent in batches. Call `Sync` to send all buffered entries, and
+// `Close` to stop the background flusher before exiting the application.
+//
+// see: https://cloud.google.com/logging/docs/reference/v2/rest/v2/entries/write
+type APICore struct {
+	zapcore.LevelEnabler
+
+	fields []zapcore.Field
+	sink   *apiSink
+}
+
+// NewAPICore returns a new core writing entries to the log `logName` in the
+// Google Cloud project `projectID`.
+func NewAPICore(projectID, logName string, options ...func(*apiConfig)) *APICore {
+	defaults := apiConfig{
+		Endpoint:      defaultAPIEndpoint,
+		Client:        http.DefaultClient,
+		LevelEnabler:  zapcore.InfoLevel,
+		BatchCount:    500,
+		BatchBytes:    1 << 20,
+		FlushInterval: time.Second,
+		MaxRetries:    5,
+		Backoff:       100 * time.Millisecond,
+		Resource:      &Resource{Type: "global"},
+	}
+
+	config := defaults
+	for _, option := range options {
+		option(&config)
+	}
+
+	// Fall back to the defaults for settings that would stop entries from
+	// being sent at all.
+	if config.Client == nil {
+		config.Client = defaults.Client
+	}
+	if config.LevelEnabler == nil {
+		config.LevelEnabler = defaults.LevelEnabler
+	}
+	if config.BatchCount <= 0 {
+		config.BatchCount = defaults.BatchCount
+	}
+	if config.BatchBytes <= 0 {
+		config.BatchBytes = defaults.BatchBytes
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaults.FlushInterval
+	}
+
+	sink := &apiSink{
+		config:  config,
+		logName: "projects/" + projectID + "/logs/" + url.PathEscape(logName),
+		done:    make(chan struct{}),
+	}
+	if config.AuditLogName != "" {
+		sink.auditLogName = "projects/" + projectID + "/logs/" + url.PathEscape(config.AuditLogName)
+	}
+
+	sink.wg.Add(1)
+	go sink.run()
+
+	return &APICore{LevelEnabler: config.LevelEnabler, sink: sink}
+}
+
+// With adds structured context to the Core.
+func (c *APICore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &APICore{
+		LevelEnabler: c.LevelEnabler,
+		fields:       make([]zapcore.Field, 0, len(c.fields)+len(fields)),
+		sink:         c.sink,
+	}
+	clone.fields = append(clone.fields, c.fields...)
+	clone.fields = append(clone.fields, fields...)
+
+	return clone
+}
+
+// Check determines whether the supplied Entry should be logged. If the entry
+// should be logged, the Core adds itself to the CheckedEntry and returns the
+// result.
+func (c *APICore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+
+	return ce
+}
+
+// Write buffers the entry to be sent to Cloud Logging. If the buffer is full,
+// or the entry is logged at a level above ErrorLevel, Write sends the buffered
+// entries before returning.
+func (c *APICore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for i := range c.fields {
+		c.fields[i].AddTo(enc)
+	}
+	for i := range fields {
+		fields[i].AddTo(enc)
+	}
+
+	entry := newAPIEntry(ent, enc.Fields)
+	if c.sink.auditLogName != "" && entry.Labels[auditLabelKey] == "true" {
+		entry.LogName = c.sink.auditLogName
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := c.sink.add(b); err != nil {
+		return err
+	}
+
+	if ent.Level > zapcore.ErrorLevel {
+		// Since we may be crashing the program, send the entries before zap
+		// panics or exits.
+		return c.sink.flush()
+	}
+
+	return nil
+}
+
+// Sync sends all buffered entries to Cloud Logging.
+func (c *APICore) Sync() error {
+	return c.sink.flush()
+}
+
+// Close stops the background flusher, and sends all buffered entries to Cloud
+// Logging.
+func (c *APICore) Close() error {
+	c.sink.closeOnce.Do(func() { close(c.sink.done) })
+	c.sink.wg.Wait()
+
+	return c.sink.flush()
+}
+
+// apiEntry is a LogEntry as accepted by the Cloud Logging API.
+//
+// see: https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry
+type apiEntry struct {
+	LogName        string                 `json:"logName,omitempty"`
+	Timestamp      string                 `json:"timestamp"`
+	Severity       string                 `json:"severity"`
+	InsertID       string                 `json:"insertId,omitempty"`
+	Labels         map[string]string      `json:"labels,omitempty"`
+	HTTPRequest    map[string]interface{} `json:"httpRequest,omitempty"`
+	Operation      map[string]interface{} `json:"operation,omitempty"`
+	SourceLocation map[string]interface{} `json:"sourceLocation,omitempty"`
+	Trace          string                 `json:"trace,omitempty"`
+	SpanID         string                 `json:"spanId,omitempty"`
+	TraceSampled   bool                   `json:"traceSampled,omitempty"`
+	JSONPayload    map[string]interface{} `json:"jsonPayload"`
+}
+
+// apiWriteRequest is the body of an `entries.write` request.
+type apiWriteRequest struct {
+	LogName        string            `json:"logName"`
+	Resource       *Resource         `json:"resource"`
+	Entries        []json.RawMessage `json:"entries"`
+	PartialSuccess bool              `json:"partialSuccess"`
+}
+
+func newAPIEntry(ent zapcore.Entry, fields map[string]interface{}) *apiEntry {
+	entry := &apiEntry{
+		Timestamp:   ent.Time.UTC().Format(time.RFC3339Nano),
+		Severity:    logLevelSeverity[ent.Level],
+		JSONPayload: map[string]interface{}{},
+	}
+
+	for key, value := range fields {
+		switch {
+		case key == labelsKey:
+			if m, ok := value.(map[string]interface{}); ok {
+				for k, v := range m {
+					entry.addLabel(k, v)
+				}
+			}
+		case strings.HasPrefix(key, "labels."):
+			entry.addLabel(strings.TrimPrefix(key, "labels."), value)
+		case key == "httpRequest":
+			entry.HTTPRequest = apiObject(value)
+		case key == operationKey:
+			entry.Operation = apiObject(value)
+		case key == sourceKey:
+			entry.SourceLocation = apiObject(value)
+		case key == insertIDKey:
+			entry.InsertID, _ = value.(string)
+		case key == traceKey:
+			entry.Trace, _ = value.(string)
+		case key == spanKey:
+			entry.SpanID, _ = value.(string)
+		case key == traceSampledKey:
+			entry.TraceSampled, _ = value.(bool)
+		default:
+			entry.JSONPayload[key] = value
+		}
+	}
+
+	if entry.SourceLocation == nil && ent.Caller.Defined {
+		entry.SourceLocation = apiObject(map[string]interface{}{
+			"file":     ent.Caller.File,
+			"line":     ent.Caller.Line,
+			"function": newSource(ent.Caller.PC, ent.Caller.File, ent.Caller.Line, true).Function,
+		})
+	}
+
+	entry.JSONPayload[encoderConfig.MessageKey] = ent.Message
+	if ent.LoggerName != "" {
+		entry.JSONPayload[encoderConfig.NameKey] = ent.LoggerName
+	}
+	if ent.Stack != "" {
+		entry.JSONPayload[encoderConfig.StacktraceKey] = ent.Stack
+	}
+
+	return entry
+}
+
+func (e *apiEntry) addLabel(key string, value interface{}) {
+	if e.Labels == nil {
+		e.Labels = map[string]string{}
+	}
+
+	e.Labels[key] = fmt.Sprint(value)
+}
+
+// apiObject returns a copy of a marshalled object field, without any empty
+// string values. The Cloud Logging API rejects empty strings for fields such as
+// `httpRequest.latency` or `httpRequest.requestSize`.
+func apiObject(value interface{}) map[string]interface{} {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if s, ok := v.(string); ok && s == "" {
+			continue
+		}
+
+		out[k] = v
+	}
+
+	return out
+}
+
+// apiSink buffers encoded entries, and sends them to Cloud Logging in batches.
+// It is shared between all cores derived from the same `NewAPICore` call.
+type apiSink struct {
+	config  apiConfig
+	logName string
+
+	// auditLogName overrides the log name of audit entries, if set.
+	auditLogName string
+
+	// mutex guards the buffered entries.
+	mutex   sync.Mutex
+	entries []json.RawMessage
+	size    int
+	err     error
+
+	// sendMutex makes sure only one batch is sent at a time. Writers that fill
+	// up the buffer while a batch is being sent block until it is done, which
+	// applies backpressure on the application when Cloud Logging is slow.
+	sendMutex sync.Mutex
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func (s *apiSink) add(entry json.RawMessage) error {
+	s.mutex.Lock()
+	s.entries = append(s.entries, entry)
+	s.size += len(entry)
+	full := len(s.entries) >= s.config.BatchCount || s.size >= s.config.BatchBytes
+	s.mutex.Unlock()
+
+	if !full {
+		return nil
+	}
+
+	return s.flush()
+}
+
+// flush sends all buffered entries. It returns the first error encountered
+// since the previous flush, including errors from background flushes.
+func (s *apiSink) flush() error {
+	s.sendMutex.Lock()
+	defer s.sendMutex.Unlock()
+
+	for {
+		batch := s.take()
+		if len(batch) == 0 {
+			break
+		}
+
+		if err := s.send(batch); err != nil {
+			s.setErr(err)
+		}
+	}
+
+	s.mutex.Lock()
+	err := s.err
+	s.err = nil
+	s.mutex.Unlock()
+
+	return err
+}
+
+// take removes the next batch of entries from the buffer.
+func (s *apiSink) take() []json.RawMessage {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, size := 0, 0
+	for n < len(s.entries) && n < s.config.BatchCount {
+		if n > 0 && size+len(s.entries[n]) > s.config.BatchBytes {
+			break
+		}
+
+		size += len(s.entries[n])
+		n++
+	}
+
+	batch := s.entries[:n:n]
+	s.entries = s.entries[n:]
+	s.size -= size
+
+	return batch
+}
+
+func (s *apiSink) setErr(err error) {
+	s.mutex.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.mutex.Unlock()
+}
+
+func (s *apiSink) send(entries []json.RawMessage) error {
+	body, err := json.Marshal(apiWriteRequest{
+		LogName:        s.logName,
+		Resource:       s.config.Resource,
+		Entries:        entries,
+		PartialSuccess: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	backoff := s.config.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(body)
+		if err == nil {
+			return nil
+		}
+
+		if !retry || attempt >= s.config.MaxRetries {
+			return fmt.Errorf("zapdriver: writing %d entries to Cloud Logging: %v", len(entries), err)
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post sends a single request to the API. It returns whether the request
+// should be retried if it failed.
+func (s *apiSink) post(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, s.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := s.config.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer res.Body.Close() // nolint: errcheck
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		_, _ = io.Copy(ioutil.Discard, res.Body) // nolint: gas
+		return false, nil
+	}
+
+	msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024)) // nolint: gas
+	err = fmt.Errorf("unexpected status %d: %s", res.StatusCode, bytes.TrimSpace(msg))
+	retry := res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+
+	return retry, err
+}
+
+// run periodically flushes the buffered entries until the sink is closed.
+func (s *apiSink) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.flush(); err != nil {
+				// Keep the error around, so it is returned by the next call to
+				// `Sync`.
+				s.setErr(err)
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
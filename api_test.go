@@ -0,0 +1,284 @@
+package zapdriver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type fakeAPIRequest struct {
+	LogName  string                   `json:"logName"`
+	Resource map[string]interface{}   `json:"resource"`
+	Entries  []map[string]interface{} `json:"entries"`
+}
+
+// fakeAPI is a local stand-in for the Cloud Logging `entries.write` method.
+type fakeAPI struct {
+	*httptest.Server
+
+	mutex    sync.Mutex
+	requests []fakeAPIRequest
+	failures int32
+}
+
+func newFakeAPI(t *testing.T) *fakeAPI {
+	api := &fakeAPI{}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&api.failures, -1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var req fakeAPIRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		api.mutex.Lock()
+		api.requests = append(api.requests, req)
+		api.mutex.Unlock()
+	}))
+
+	return api
+}
+
+func (api *fakeAPI) all() []fakeAPIRequest {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+
+	return append([]fakeAPIRequest(nil), api.requests...)
+}
+
+func TestAPICore(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.Close()
+
+	core := NewAPICore("my-project", "my/log", APIEndpoint(api.URL), APIFlushInterval(time.Hour))
+	defer core.Close() // nolint: errcheck
+
+	logger := zap.New(core, WrapCore(ServiceName("my service")), zap.AddCaller())
+	logger = logger.With(Label("one", "1"))
+	fields := []zap.Field{
+		Label("two", "2"),
+		zap.String("hello", "universe"),
+		HTTP(&HTTPPayload{RequestMethod: "GET", Status: 200}),
+		OperationStart("id", "producer"),
+		InsertID("abc"),
+	}
+	fields = append(fields, TraceContext("105445aa7843bc8bf206b120001000", "0", true, "my-project")...)
+	logger.Info("hello world", fields...)
+
+	require.NoError(t, logger.Sync())
+
+	requests := api.all()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "projects/my-project/logs/my%2Flog", requests[0].LogName)
+	assert.Equal(t, "global", requests[0].Resource["type"])
+	require.Len(t, requests[0].Entries, 1)
+
+	entry := requests[0].Entries[0]
+	assert.Equal(t, "INFO", entry["severity"])
+	assert.Equal(t, "abc", entry["insertId"])
+	assert.NotEmpty(t, entry["timestamp"])
+	assert.Equal(t, map[string]interface{}{"one": "1", "two": "2"}, entry["labels"])
+	assert.Equal(t, "projects/my-project/traces/105445aa7843bc8bf206b120001000", entry["trace"])
+	assert.Equal(t, "0", entry["spanId"])
+	assert.Equal(t, true, entry["traceSampled"])
+
+	httpRequest := entry["httpRequest"].(map[string]interface{})
+	assert.Equal(t, "GET", httpRequest["requestMethod"])
+	assert.Equal(t, float64(200), httpRequest["status"])
+	assert.NotContains(t, httpRequest, "latency")
+
+	operation := entry["operation"].(map[string]interface{})
+	assert.Equal(t, "id", operation["id"])
+	assert.Equal(t, true, operation["first"])
+
+	source := entry["sourceLocation"].(map[string]interface{})
+	assert.Contains(t, source["file"], "zapdriver/api_test.go")
+	assert.Contains(t, source["function"], "zapdriver.TestAPICore")
+
+	payload := entry["jsonPayload"].(map[string]interface{})
+	assert.Equal(t, "hello world", payload["message"])
+	assert.Equal(t, "universe", payload["hello"])
+	assert.Equal(t, map[string]interface{}{"service": "my service"}, payload["serviceContext"])
+	assert.NotContains(t, payload, labelsKey)
+	assert.NotContains(t, payload, "httpRequest")
+}
+
+func TestAPICore_Level(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.Close()
+
+	core := NewAPICore("my-project", "my-log", APIEndpoint(api.URL), APILevel(zapcore.WarnLevel))
+	defer core.Close() // nolint: errcheck
+
+	logger := zap.New(core)
+	logger.Info("hello world")
+	logger.Warn("hello universe")
+	require.NoError(t, logger.Sync())
+
+	requests := api.all()
+	require.Len(t, requests, 1)
+	require.Len(t, requests[0].Entries, 1)
+	assert.Equal(t, "WARNING", requests[0].Entries[0]["severity"])
+}
+
+func TestAPICore_Batching(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.Close()
+
+	core := NewAPICore("my-project", "my-log", APIEndpoint(api.URL), APIBatch(2, 1<<20), APIFlushInterval(time.Hour))
+	defer core.Close() // nolint: errcheck
+
+	logger := zap.New(core)
+	logger.Info("one")
+	assert.Len(t, api.all(), 0)
+
+	logger.Info("two")
+	require.Len(t, api.all(), 1)
+	assert.Len(t, api.all()[0].Entries, 2)
+
+	logger.Info("three")
+	require.NoError(t, core.Close())
+	require.Len(t, api.all(), 2)
+	assert.Len(t, api.all()[1].Entries, 1)
+}
+
+func TestAPICore_FlushAboveError(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.Close()
+
+	core := NewAPICore("my-project", "my-log", APIEndpoint(api.URL), APIFlushInterval(time.Hour))
+	defer core.Close() // nolint: errcheck
+
+	logger := zap.New(core)
+	logger.Error("hello world")
+	assert.Len(t, api.all(), 0)
+
+	logger.DPanic("hello universe")
+
+	requests := api.all()
+	require.Len(t, requests, 1)
+	require.Len(t, requests[0].Entries, 2)
+	assert.Equal(t, "ERROR", requests[0].Entries[0]["severity"])
+	assert.Equal(t, "CRITICAL", requests[0].Entries[1]["severity"])
+}
+
+func TestAPICore_FlushInterval(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.Close()
+
+	core := NewAPICore("my-project", "my-log", APIEndpoint(api.URL), APIFlushInterval(10*time.Millisecond))
+	defer core.Close() // nolint: errcheck
+
+	zap.New(core).Info("hello world")
+
+	for i := 0; i < 100 && len(api.all()) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Len(t, api.all(), 1)
+}
+
+func TestAPICore_Retries(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.Close()
+	api.failures = 2
+
+	core := NewAPICore("my-project", "my-log", APIEndpoint(api.URL), APIRetries(2, time.Millisecond))
+	defer core.Close() // nolint: errcheck
+
+	logger := zap.New(core)
+	logger.Info("hello world")
+	require.NoError(t, logger.Sync())
+
+	assert.Len(t, api.all(), 1)
+}
+
+func TestAPICore_RetriesExhausted(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.Close()
+	api.failures = 3
+
+	core := NewAPICore("my-project", "my-log", APIEndpoint(api.URL), APIRetries(2, time.Millisecond))
+	defer core.Close() // nolint: errcheck
+
+	logger := zap.New(core)
+	logger.Info("hello world")
+	err := logger.Sync()
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "503")
+	assert.Len(t, api.all(), 0)
+}
+
+func TestAPICore_Resource(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.Close()
+
+	resource := &Resource{Type: "k8s_container", Labels: map[string]string{"pod_name": "my-pod"}}
+	core := NewAPICore("my-project", "my-log", APIEndpoint(api.URL), APIResource(resource))
+	defer core.Close() // nolint: errcheck
+
+	logger := zap.New(core)
+	logger.Info("hello world")
+	require.NoError(t, logger.Sync())
+
+	requests := api.all()
+	require.Len(t, requests, 1)
+	assert.Equal(t, map[string]interface{}{
+		"type":   "k8s_container",
+		"labels": map[string]interface{}{"pod_name": "my-pod"},
+	}, requests[0].Resource)
+}
+
+func TestAPICore_AuditLogName(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.Close()
+
+	core := NewAPICore("my-project", "my-log", APIEndpoint(api.URL), APIAuditLogName("audit"))
+	defer core.Close() // nolint: errcheck
+
+	logger := zap.New(core)
+	logger.Info("hello world")
+	logger.Info("order cancelled", Audit(&AuditPayload{MethodName: "CancelOrder"})...)
+	require.NoError(t, logger.Sync())
+
+	requests := api.all()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "projects/my-project/logs/my-log", requests[0].LogName)
+	require.Len(t, requests[0].Entries, 2)
+	assert.NotContains(t, requests[0].Entries[0], "logName")
+	assert.Equal(t, "projects/my-project/logs/audit", requests[0].Entries[1]["logName"])
+
+	payload := requests[0].Entries[1]["jsonPayload"].(map[string]interface{})
+	assert.Equal(t, "CancelOrder", payload["protoPayload"].(map[string]interface{})["methodName"])
+}
+
+func TestAPICore_InvalidOptions(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.Close()
+
+	core := NewAPICore("my-project", "my-log", APIEndpoint(api.URL), APIBatch(0, -1), APIFlushInterval(0))
+	defer core.Close() // nolint: errcheck
+
+	assert.Equal(t, 500, core.sink.config.BatchCount)
+	assert.Equal(t, 1<<20, core.sink.config.BatchBytes)
+	assert.Equal(t, time.Second, core.sink.config.FlushInterval)
+
+	logger := zap.New(core)
+	logger.Info("hello world")
+	require.NoError(t, logger.Sync())
+
+	requests := api.all()
+	require.Len(t, requests, 1)
+	assert.Len(t, requests[0].Entries, 1)
+}
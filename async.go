@@ -0,0 +1,378 @@
+package zapdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// OverflowPolicy decides what an AsyncWriter does when its buffer is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks the writing goroutine until there is room in the
+	// buffer.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropNewest drops the entry that is being written.
+	OverflowDropNewest
+
+	// OverflowDropLowestSeverity drops the buffered entry with the lowest
+	// severity, or the entry that is being written if no buffered entry has a
+	// lower severity.
+	OverflowDropLowestSeverity
+)
+
+// asyncConfig is used to configure an AsyncWriter.
+type asyncConfig struct {
+	// BufferSize is the maximum number of buffered entries.
+	BufferSize int
+
+	// Overflow decides what happens when the buffer is full.
+	Overflow OverflowPolicy
+
+	// DropReportInterval is the interval at which a WARNING entry is written
+	// reporting the number of entries dropped since the previous report.
+	DropReportInterval time.Duration
+}
+
+// AsyncBufferSize sets the maximum number of entries buffered by an
+// AsyncWriter. It defaults to 1024, which is also used for sizes of zero or
+// less.
+func AsyncBufferSize(size int) func(*asyncConfig) {
+	return func(c *asyncConfig) {
+		c.BufferSize = size
+	}
+}
+
+// AsyncOverflow sets what an AsyncWriter does when its buffer is full. It
+// defaults to OverflowBlock.
+func AsyncOverflow(policy OverflowPolicy) func(*asyncConfig) {
+	return func(c *asyncConfig) {
+		c.Overflow = policy
+	}
+}
+
+// AsyncDropReportInterval sets the interval at which an AsyncWriter reports the
+// number of dropped entries. It defaults to one minute, which is also used for
+// intervals of zero or less.
+func AsyncDropReportInterval(interval time.Duration) func(*asyncConfig) {
+	return func(c *asyncConfig) {
+		c.DropReportInterval = interval
+	}
+}
+
+// asyncEntry is a single encoded log entry, buffered by an AsyncWriter.
+type asyncEntry struct {
+	b        []byte
+	severity string
+}
+
+// AsyncWriter is a zapcore.WriteSyncer that buffers encoded log entries, and
+// writes them to the underlying WriteSyncer from a background goroutine.
+//
+// It expects to receive entries encoded by a Zapdriver encoder, as it parses
+// their severity to decide which entries to drop when the buffer is full, and to
+// write CRITICAL entries and above synchronously.
+type AsyncWriter struct {
+	ws     zapcore.WriteSyncer
+	config asyncConfig
+
+	// mutex guards the ring buffer and the drop counters. notFull is signalled
+	// whenever entries are removed from the buffer.
+	mutex   sync.Mutex
+	notFull *sync.Cond
+	ring    []asyncEntry
+	head    int
+	count   int
+	dropped map[string]uint64
+	total   map[string]uint64
+
+	// writeMutex makes sure entries are written to the underlying WriteSyncer in
+	// the order in which they were buffered.
+	writeMutex sync.Mutex
+
+	wake      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewAsyncWriter returns a new AsyncWriter writing to ws.
+func NewAsyncWriter(ws zapcore.WriteSyncer, options ...func(*asyncConfig)) *AsyncWriter {
+	defaults := asyncConfig{
+		BufferSize:         1024,
+		Overflow:           OverflowBlock,
+		DropReportInterval: time.Minute,
+	}
+
+	config := defaults
+	for _, option := range options {
+		option(&config)
+	}
+
+	if config.BufferSize <= 0 {
+		config.BufferSize = defaults.BufferSize
+	}
+	if config.DropReportInterval <= 0 {
+		config.DropReportInterval = defaults.DropReportInterval
+	}
+
+	w := &AsyncWriter{
+		ws:      ws,
+		config:  config,
+		ring:    make([]asyncEntry, config.BufferSize),
+		dropped: map[string]uint64{},
+		total:   map[string]uint64{},
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	w.notFull = sync.NewCond(&w.mutex)
+
+	w.wg.Add(1)
+	go w.run()
+
+	return w
+}
+
+// Write buffers a single encoded log entry.
+func (w *AsyncWriter) Write(p []byte) (int, error) {
+	entry := asyncEntry{
+		b:        append([]byte(nil), p...),
+		severity: parseSeverity(p),
+	}
+
+	// Entries are written synchronously if they are CRITICAL or above (Zap exits
+	// or panics right after writing those), or if the writer is closed.
+	if severityRanks[entry.severity] >= severityRanks["CRITICAL"] || w.closed() {
+		w.writeMutex.Lock()
+		defer w.writeMutex.Unlock()
+
+		if err := w.drain(); err != nil {
+			return 0, err
+		}
+		if _, err := w.ws.Write(entry.b); err != nil {
+			return 0, err
+		}
+
+		return len(p), w.ws.Sync()
+	}
+
+	w.push(entry)
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+
+	return len(p), nil
+}
+
+// Sync writes all buffered entries, and syncs the underlying WriteSyncer.
+func (w *AsyncWriter) Sync() error {
+	w.writeMutex.Lock()
+	defer w.writeMutex.Unlock()
+
+	if err := w.drain(); err != nil {
+		return err
+	}
+
+	return w.ws.Sync()
+}
+
+// Close stops the background goroutine, writes all buffered entries and
+// reports any entries dropped since the last report.
+func (w *AsyncWriter) Close() error {
+	w.closeOnce.Do(func() { close(w.done) })
+	w.wg.Wait()
+
+	if err := w.reportDropped(); err != nil {
+		return err
+	}
+
+	return w.Sync()
+}
+
+// Dropped returns the total number of dropped entries per severity.
+func (w *AsyncWriter) Dropped() map[string]uint64 {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	out := make(map[string]uint64, len(w.total))
+	for k, v := range w.total {
+		out[k] = v
+	}
+
+	return out
+}
+
+func (w *AsyncWriter) closed() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *AsyncWriter) push(entry asyncEntry) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for w.count == len(w.ring) {
+		switch w.config.Overflow {
+		case OverflowDropNewest:
+			w.drop(entry.severity)
+			return
+		case OverflowDropLowestSeverity:
+			if !w.dropLowerSeverity(entry.severity) {
+				w.drop(entry.severity)
+				return
+			}
+		default:
+			w.notFull.Wait()
+		}
+	}
+
+	w.ring[(w.head+w.count)%len(w.ring)] = entry
+	w.count++
+}
+
+// dropLowerSeverity removes the oldest buffered entry with the lowest severity,
+// if that severity is lower than the passed in severity. It returns whether an
+// entry was removed.
+func (w *AsyncWriter) dropLowerSeverity(severity string) bool {
+	lowest := -1
+	for i := 0; i < w.count; i++ {
+		s := w.ring[(w.head+i)%len(w.ring)].severity
+		if lowest == -1 || severityRanks[s] < severityRanks[w.ring[(w.head+lowest)%len(w.ring)].severity] {
+			lowest = i
+		}
+	}
+
+	victim := w.ring[(w.head+lowest)%len(w.ring)]
+	if severityRanks[victim.severity] >= severityRanks[severity] {
+		return false
+	}
+
+	for i := lowest; i < w.count-1; i++ {
+		w.ring[(w.head+i)%len(w.ring)] = w.ring[(w.head+i+1)%len(w.ring)]
+	}
+	w.count--
+	w.ring[(w.head+w.count)%len(w.ring)] = asyncEntry{}
+	w.drop(victim.severity)
+
+	return true
+}
+
+func (w *AsyncWriter) drop(severity string) {
+	w.dropped[severity]++
+	w.total[severity]++
+}
+
+// drain writes all buffered entries to the underlying WriteSyncer. The caller
+// must hold writeMutex.
+func (w *AsyncWriter) drain() error {
+	w.mutex.Lock()
+	entries := make([]asyncEntry, 0, w.count)
+	for w.count > 0 {
+		entries = append(entries, w.ring[w.head])
+		w.ring[w.head] = asyncEntry{}
+		w.head = (w.head + 1) % len(w.ring)
+		w.count--
+	}
+	w.notFull.Broadcast()
+	w.mutex.Unlock()
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	buf := &bytes.Buffer{}
+	for i := range entries {
+		buf.Write(entries[i].b)
+	}
+
+	_, err := w.ws.Write(buf.Bytes())
+	return err
+}
+
+// reportDropped writes a WARNING entry with the number of entries dropped since
+// the previous report, if any.
+func (w *AsyncWriter) reportDropped() error {
+	w.mutex.Lock()
+	dropped := w.dropped
+	w.dropped = map[string]uint64{}
+	w.mutex.Unlock()
+
+	var total uint64
+	for _, n := range dropped {
+		total += n
+	}
+
+	if total == 0 {
+		return nil
+	}
+
+	b, err := json.Marshal(map[string]interface{}{
+		encoderConfig.LevelKey:   "WARNING",
+		encoderConfig.TimeKey:    time.Now().Format(time.RFC3339Nano),
+		encoderConfig.MessageKey: "zapdriver: dropped log entries",
+		"dropped":                total,
+		"droppedBySeverity":      dropped,
+	})
+	if err != nil {
+		return err
+	}
+
+	w.writeMutex.Lock()
+	defer w.writeMutex.Unlock()
+
+	if err := w.drain(); err != nil {
+		return err
+	}
+
+	_, err = w.ws.Write(append(b, encoderConfig.LineEnding...))
+	return err
+}
+
+func (w *AsyncWriter) run() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.config.DropReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.wake:
+			w.writeMutex.Lock()
+			_ = w.drain() // nolint: gas
+			w.writeMutex.Unlock()
+		case <-ticker.C:
+			_ = w.reportDropped() // nolint: gas
+		case <-w.done:
+			return
+		}
+	}
+}
+
+var severityPrefix = []byte(`"` + encoderConfig.LevelKey + `":"`)
+
+// parseSeverity returns the severity of a JSON encoded log entry, without
+// decoding the entire entry.
+func parseSeverity(p []byte) string {
+	i := bytes.Index(p, severityPrefix)
+	if i == -1 {
+		return "DEFAULT"
+	}
+
+	p = p[i+len(severityPrefix):]
+	if j := bytes.IndexByte(p, '"'); j != -1 {
+		return string(p[:j])
+	}
+
+	return "DEFAULT"
+}
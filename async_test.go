@@ -0,0 +1,203 @@
+package zapdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// bufferSyncer is a thread-safe WriteSyncer writing to a buffer.
+type bufferSyncer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+	syncs int
+}
+
+func (b *bufferSyncer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *bufferSyncer) Sync() error {
+	b.mutex.Lock()
+	b.syncs++
+	b.mutex.Unlock()
+
+	return nil
+}
+
+func (b *bufferSyncer) lines() []string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return strings.Split(strings.TrimSpace(b.buf.String()), "\n")
+}
+
+func asyncLine(severity, message string) []byte {
+	return []byte(`{"severity":"` + severity + `","message":"` + message + `"}` + "\n")
+}
+
+func TestParseSeverity(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "ERROR", parseSeverity(asyncLine("ERROR", "hello world")))
+	assert.Equal(t, "DEFAULT", parseSeverity([]byte(`{"message":"hello world"}`)))
+	assert.Equal(t, "DEFAULT", parseSeverity([]byte(`hello world`)))
+}
+
+func TestAsyncWriter(t *testing.T) {
+	ws := &bufferSyncer{}
+	w := NewAsyncWriter(ws)
+	defer w.Close() // nolint: errcheck
+
+	logger := zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), w, zapcore.DebugLevel))
+	for i := 0; i < 100; i++ {
+		logger.Info("hello world", zap.Int("i", i))
+	}
+	require.NoError(t, logger.Sync())
+
+	lines := ws.lines()
+	require.Len(t, lines, 100)
+
+	for i := range lines {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(lines[i]), &entry))
+		assert.Equal(t, float64(i), entry["i"])
+	}
+}
+
+func TestAsyncWriter_InvalidOptions(t *testing.T) {
+	ws := &bufferSyncer{}
+	w := NewAsyncWriter(ws, AsyncBufferSize(0), AsyncDropReportInterval(0))
+	defer w.Close() // nolint: errcheck
+
+	assert.Equal(t, 1024, w.config.BufferSize)
+	assert.Equal(t, time.Minute, w.config.DropReportInterval)
+
+	_, err := w.Write(asyncLine("INFO", "hello world"))
+	require.NoError(t, err)
+	require.NoError(t, w.Sync())
+
+	assert.Equal(t, []string{strings.TrimSpace(string(asyncLine("INFO", "hello world")))}, ws.lines())
+}
+
+func TestAsyncWriter_DropNewest(t *testing.T) {
+	ws := &bufferSyncer{}
+	w := NewAsyncWriter(ws, AsyncBufferSize(2), AsyncOverflow(OverflowDropNewest))
+	defer w.Close() // nolint: errcheck
+
+	// Prevent the background goroutine from writing buffered entries.
+	w.writeMutex.Lock()
+	_, _ = w.Write(asyncLine("INFO", "one"))
+	_, _ = w.Write(asyncLine("INFO", "two"))
+	_, _ = w.Write(asyncLine("ERROR", "three"))
+	w.writeMutex.Unlock()
+
+	require.NoError(t, w.Sync())
+
+	lines := ws.lines()
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "one")
+	assert.Contains(t, lines[1], "two")
+	assert.Equal(t, map[string]uint64{"ERROR": 1}, w.Dropped())
+}
+
+func TestAsyncWriter_DropLowestSeverity(t *testing.T) {
+	ws := &bufferSyncer{}
+	w := NewAsyncWriter(ws, AsyncBufferSize(2), AsyncOverflow(OverflowDropLowestSeverity))
+	defer w.Close() // nolint: errcheck
+
+	w.writeMutex.Lock()
+	_, _ = w.Write(asyncLine("DEBUG", "one"))
+	_, _ = w.Write(asyncLine("INFO", "two"))
+	_, _ = w.Write(asyncLine("ERROR", "three"))
+	_, _ = w.Write(asyncLine("DEBUG", "four"))
+	w.writeMutex.Unlock()
+
+	require.NoError(t, w.Sync())
+
+	lines := ws.lines()
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "two")
+	assert.Contains(t, lines[1], "three")
+	assert.Equal(t, map[string]uint64{"DEBUG": 2}, w.Dropped())
+}
+
+func TestAsyncWriter_Block(t *testing.T) {
+	ws := &bufferSyncer{}
+	w := NewAsyncWriter(ws, AsyncBufferSize(1))
+	defer w.Close() // nolint: errcheck
+
+	w.writeMutex.Lock()
+	_, _ = w.Write(asyncLine("INFO", "one"))
+
+	written := make(chan struct{})
+	go func() {
+		_, _ = w.Write(asyncLine("INFO", "two"))
+		close(written)
+	}()
+
+	select {
+	case <-written:
+		t.Fatal("expected write to block while the buffer is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	w.writeMutex.Unlock()
+	<-written
+
+	require.NoError(t, w.Sync())
+	assert.Len(t, ws.lines(), 2)
+	assert.Empty(t, w.Dropped())
+}
+
+func TestAsyncWriter_CriticalIsSynchronous(t *testing.T) {
+	ws := &bufferSyncer{}
+	w := NewAsyncWriter(ws, AsyncDropReportInterval(time.Hour))
+	defer w.Close() // nolint: errcheck
+
+	_, _ = w.Write(asyncLine("INFO", "one"))
+	_, _ = w.Write(asyncLine("ALERT", "two"))
+
+	lines := ws.lines()
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "one")
+	assert.Contains(t, lines[1], "two")
+	assert.Equal(t, 1, ws.syncs)
+}
+
+func TestAsyncWriter_ReportDropped(t *testing.T) {
+	ws := &bufferSyncer{}
+	w := NewAsyncWriter(ws, AsyncBufferSize(1), AsyncOverflow(OverflowDropNewest))
+
+	w.writeMutex.Lock()
+	_, _ = w.Write(asyncLine("INFO", "one"))
+	_, _ = w.Write(asyncLine("INFO", "two"))
+	_, _ = w.Write(asyncLine("DEBUG", "three"))
+	w.writeMutex.Unlock()
+
+	require.NoError(t, w.Close())
+
+	lines := ws.lines()
+	require.Len(t, lines, 2)
+
+	var report map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &report))
+	assert.Equal(t, "WARNING", report["severity"])
+	assert.Equal(t, float64(2), report["dropped"])
+	assert.Equal(t, map[string]interface{}{"INFO": float64(1), "DEBUG": float64(1)}, report["droppedBySeverity"])
+
+	// Entries written after closing are written synchronously.
+	_, _ = w.Write(asyncLine("INFO", "four"))
+	assert.Len(t, ws.lines(), 3)
+}
@@ -0,0 +1,148 @@
+package zapdriver
+
+import (
+	"net"
+	"net/http"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	auditKey      = "protoPayload"
+	auditType     = "type.googleapis.com/google.cloud.audit.AuditLog"
+	auditLabelKey = "audit"
+)
+
+// AuditPayload describes who did what to which resource, in the shape of a
+// Cloud Audit Logs entry.
+//
+// see: https://cloud.google.com/logging/docs/reference/audit/auditlog/rest/Shared.Types/AuditLog
+type AuditPayload struct {
+	// The email address of the authenticated user (or service account) making
+	// the request.
+	PrincipalEmail string
+
+	// The name of the API service performing the operation.
+	//
+	// Example: "orders.example.com".
+	ServiceName string
+
+	// The name of the service method or operation.
+	//
+	// Example: "orders.v1.Orders.CancelOrder".
+	MethodName string
+
+	// The resource or collection that is the target of the operation.
+	//
+	// Example: "customers/123/orders/456".
+	ResourceName string
+
+	// The status of the overall operation, as a google.rpc.Code. Zero means OK.
+	//
+	// see: https://cloud.google.com/apis/design/errors#handling_errors
+	StatusCode int
+
+	// A developer-facing error message, if the operation failed.
+	StatusMessage string
+
+	// The request the operation was performed for. Its remote IP (without the
+	// port) and user agent end up in the `requestMetadata` of the entry.
+	Request *HTTPPayload
+}
+
+// NewAudit returns a new AuditPayload for an operation performed for the
+// passed in http.Request.
+func NewAudit(req *http.Request, principalEmail, methodName, resourceName string) *AuditPayload {
+	a := &AuditPayload{
+		PrincipalEmail: principalEmail,
+		MethodName:     methodName,
+		ResourceName:   resourceName,
+	}
+
+	if req != nil {
+		a.ServiceName = req.Host
+		a.Request = &HTTPPayload{
+			RequestMethod: req.Method,
+			UserAgent:     req.UserAgent(),
+			RemoteIP:      callerIP(req.RemoteAddr),
+			Protocol:      req.Proto,
+		}
+
+		if req.URL != nil {
+			a.Request.RequestURL = req.URL.String()
+		}
+	}
+
+	return a
+}
+
+// Audit adds a Cloud Audit Logs "protoPayload" field to the entry, together
+// with the `audit` label that marks it as an audit entry. Use the
+// `APIAuditLogName` option to write audit entries to a separate log.
+func Audit(a *AuditPayload) []zap.Field {
+	return []zap.Field{
+		zap.Object(auditKey, a),
+		Label(auditLabelKey, "true"),
+	}
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+func (a AuditPayload) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("@type", auditType)
+
+	if a.ServiceName != "" {
+		enc.AddString("serviceName", a.ServiceName)
+	}
+	enc.AddString("methodName", a.MethodName)
+	enc.AddString("resourceName", a.ResourceName)
+
+	err := enc.AddObject("authenticationInfo", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		if a.PrincipalEmail != "" {
+			enc.AddString("principalEmail", a.PrincipalEmail)
+		}
+
+		return nil
+	}))
+	if err != nil {
+		return err
+	}
+
+	err = enc.AddObject("status", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		enc.AddInt("code", a.StatusCode)
+		if a.StatusMessage != "" {
+			enc.AddString("message", a.StatusMessage)
+		}
+
+		return nil
+	}))
+	if err != nil {
+		return err
+	}
+
+	if r := a.Request; r != nil {
+		return enc.AddObject("requestMetadata", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			if r.RemoteIP != "" {
+				enc.AddString("callerIp", callerIP(r.RemoteIP))
+			}
+			if r.UserAgent != "" {
+				enc.AddString("callerSuppliedUserAgent", r.UserAgent)
+			}
+
+			return nil
+		}))
+	}
+
+	return nil
+}
+
+// callerIP returns the IP address of a remote address, such as "192.0.2.1" for
+// "192.0.2.1:1234". The address is returned as is if it has no port.
+func callerIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+
+	return host
+}
@@ -0,0 +1,100 @@
+package zapdriver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestAudit(t *testing.T) {
+	t.Parallel()
+
+	a := &AuditPayload{MethodName: "CancelOrder"}
+	fields := Audit(a)
+
+	assert.Equal(t, []zap.Field{zap.Object("protoPayload", a), Label("audit", "true")}, fields)
+}
+
+func TestNewAudit(t *testing.T) {
+	t.Parallel()
+
+	r := httptest.NewRequest(http.MethodPost, "http://orders.example.com/v1/orders/456:cancel", nil)
+	r.Header.Set("User-Agent", "curl/7.64")
+
+	a := NewAudit(r, "jane@example.com", "orders.v1.Orders.CancelOrder", "orders/456")
+
+	assert.Equal(t, "jane@example.com", a.PrincipalEmail)
+	assert.Equal(t, "orders.example.com", a.ServiceName)
+	assert.Equal(t, "orders.v1.Orders.CancelOrder", a.MethodName)
+	assert.Equal(t, "orders/456", a.ResourceName)
+	require.NotNil(t, a.Request)
+	assert.Equal(t, "POST", a.Request.RequestMethod)
+	assert.Equal(t, "curl/7.64", a.Request.UserAgent)
+	assert.Equal(t, "192.0.2.1", a.Request.RemoteIP)
+}
+
+func TestCallerIP(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"192.0.2.1:1234":   "192.0.2.1",
+		"192.0.2.1":        "192.0.2.1",
+		"[2001:db8::1]:80": "2001:db8::1",
+		"2001:db8::1":      "2001:db8::1",
+		"":                 "",
+	}
+
+	for addr, want := range tests {
+		assert.Equal(t, want, callerIP(addr), addr)
+	}
+}
+
+func TestAuditPayload_MarshalLogObject(t *testing.T) {
+	t.Parallel()
+
+	a := AuditPayload{
+		PrincipalEmail: "jane@example.com",
+		ServiceName:    "orders.example.com",
+		MethodName:     "orders.v1.Orders.CancelOrder",
+		ResourceName:   "orders/456",
+		StatusCode:     7,
+		StatusMessage:  "permission denied",
+		Request:        &HTTPPayload{RemoteIP: "192.0.2.1:1234", UserAgent: "curl/7.64"},
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	require.NoError(t, a.MarshalLogObject(enc))
+
+	assert.Equal(t, map[string]interface{}{
+		"@type":              "type.googleapis.com/google.cloud.audit.AuditLog",
+		"serviceName":        "orders.example.com",
+		"methodName":         "orders.v1.Orders.CancelOrder",
+		"resourceName":       "orders/456",
+		"authenticationInfo": map[string]interface{}{"principalEmail": "jane@example.com"},
+		"status":             map[string]interface{}{"code": 7, "message": "permission denied"},
+		"requestMetadata": map[string]interface{}{
+			"callerIp":                "192.0.2.1",
+			"callerSuppliedUserAgent": "curl/7.64",
+		},
+	}, enc.Fields)
+}
+
+func TestAuditPayload_MarshalLogObject_Minimal(t *testing.T) {
+	t.Parallel()
+
+	enc := zapcore.NewMapObjectEncoder()
+	require.NoError(t, AuditPayload{MethodName: "Delete"}.MarshalLogObject(enc))
+
+	assert.Equal(t, map[string]interface{}{
+		"@type":              "type.googleapis.com/google.cloud.audit.AuditLog",
+		"methodName":         "Delete",
+		"resourceName":       "",
+		"authenticationInfo": map[string]interface{}{},
+		"status":             map[string]interface{}{"code": 0},
+	}, enc.Fields)
+}
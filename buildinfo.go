@@ -0,0 +1,34 @@
+//go:build go1.18
+// +build go1.18
+
+package zapdriver
+
+import "runtime/debug"
+
+// mainModule returns the module path and package path of the main package, as
+// recorded in the build info of the binary.
+func mainModule() (string, string) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "", ""
+	}
+
+	return info.Main.Path, info.Path
+}
+
+// buildRevision returns the VCS revision the binary was built from, as
+// recorded in its build info, or an empty string if it is unknown.
+func buildRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,24 @@
+//go:build !go1.18
+// +build !go1.18
+
+package zapdriver
+
+import "runtime/debug"
+
+// mainModule returns the module path of the main module, as recorded in the
+// build info of the binary. The package path of the main package is only
+// recorded since Go 1.18, so it is always empty.
+func mainModule() (string, string) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "", ""
+	}
+
+	return info.Main.Path, ""
+}
+
+// buildRevision returns an empty string, since the VCS revision is only
+// recorded in the build info since Go 1.18.
+func buildRevision() string {
+	return ""
+}
@@ -0,0 +1,473 @@
+// Command zapdriver-lint validates newline-delimited JSON logs against the
+// structured logging format of Cloud Logging, and reports every field that
+// would be rejected or not recognized.
+//
+// It reads the files given as arguments, or standard input if there are none,
+// and exits with status 1 if any issues are found.
+//
+// Usage:
+//
+//	go test ./... -run TestLogs -v 2>&1 | zapdriver-lint
+//	zapdriver-lint -ignore ZD011 recorded.log
+//
+// see: https://cloud.google.com/logging/docs/structured-logging
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blendle/zapdriver"
+)
+
+// Rule codes reported by the linter.
+const (
+	ruleInvalidJSON    = "ZD001"
+	ruleSeverity       = "ZD002"
+	ruleTimestamp      = "ZD003"
+	ruleLabels         = "ZD004"
+	ruleHTTPRequest    = "ZD005"
+	ruleHTTPLatency    = "ZD006"
+	ruleHTTPType       = "ZD007"
+	ruleSourceLocation = "ZD008"
+	ruleOperation      = "ZD009"
+	ruleTrace          = "ZD010"
+	ruleUnknownKey     = "ZD011"
+)
+
+// rules describes all rules, by their code.
+var rules = map[string]string{
+	ruleInvalidJSON:    "line is not a JSON object",
+	ruleSeverity:       "severity is not a known LogSeverity",
+	ruleTimestamp:      "timestamp is not an RFC 3339 string or a seconds/nanos object",
+	ruleLabels:         "labels are not an object with string values",
+	ruleHTTPRequest:    "httpRequest is not an object, or contains unknown fields",
+	ruleHTTPLatency:    "httpRequest.latency is not a duration such as \"3.5s\"",
+	ruleHTTPType:       "httpRequest field has the wrong type",
+	ruleSourceLocation: "sourceLocation is not an object with file, line and function",
+	ruleOperation:      "operation is not an object with id, producer, first and last",
+	ruleTrace:          "trace, spanId, insertId or trace_sampled has the wrong type",
+	ruleUnknownKey:     "unknown logging.googleapis.com/ field",
+}
+
+const specialPrefix = "logging.googleapis.com/"
+
+// knownSpecialKeys are the special logging.googleapis.com/ fields recognized by
+// Cloud Logging.
+var knownSpecialKeys = map[string]bool{
+	specialPrefix + "insertId":       true,
+	specialPrefix + "labels":         true,
+	specialPrefix + "operation":      true,
+	specialPrefix + "sourceLocation": true,
+	specialPrefix + "spanId":         true,
+	specialPrefix + "trace":          true,
+	specialPrefix + "trace_sampled":  true,
+}
+
+// httpRequestTypes maps the fields of a HttpRequest to their JSON type.
+var httpRequestTypes = map[string]string{
+	"requestMethod":                  "string",
+	"requestUrl":                     "string",
+	"requestSize":                    "int64",
+	"status":                         "int32",
+	"responseSize":                   "int64",
+	"userAgent":                      "string",
+	"remoteIp":                       "string",
+	"serverIp":                       "string",
+	"referer":                        "string",
+	"latency":                        "duration",
+	"cacheLookup":                    "bool",
+	"cacheHit":                       "bool",
+	"cacheValidatedWithOriginServer": "bool",
+	"cacheFillBytes":                 "int64",
+	"protocol":                       "string",
+}
+
+// latencyPattern matches a protobuf Duration in its JSON representation.
+var latencyPattern = regexp.MustCompile(`^-?\d+(\.\d{1,9})?s$`)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// issue is a single problem found in a log entry.
+type issue struct {
+	code    string
+	field   string
+	message string
+}
+
+// linter validates log entries, and keeps track of the issues found.
+type linter struct {
+	out     io.Writer
+	ignore  map[string]bool
+	entries int
+	counts  map[string]int
+
+	issues []issue
+}
+
+func run(args []string, in io.Reader, out, errOut io.Writer) int {
+	fs := flag.NewFlagSet("zapdriver-lint", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	ignore := fs.String("ignore", "", "comma-separated list of rule `codes` to ignore")
+	list := fs.Bool("rules", false, "list all rules and exit")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *list {
+		codes := make([]string, 0, len(rules))
+		for code := range rules {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+
+		for _, code := range codes {
+			fmt.Fprintf(out, "%s  %s\n", code, rules[code]) // nolint: errcheck
+		}
+
+		return 0
+	}
+
+	l := &linter{out: out, ignore: map[string]bool{}, counts: map[string]int{}}
+	for _, code := range strings.Split(*ignore, ",") {
+		if code = strings.TrimSpace(code); code != "" {
+			if _, ok := rules[code]; !ok {
+				fmt.Fprintf(errOut, "zapdriver-lint: unknown rule %q\n", code) // nolint: errcheck
+				return 2
+			}
+
+			l.ignore[code] = true
+		}
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	for _, name := range files {
+		if err := l.lintFile(name, in); err != nil {
+			fmt.Fprintf(errOut, "zapdriver-lint: %v\n", err) // nolint: errcheck
+			return 2
+		}
+	}
+
+	return l.summary()
+}
+
+func (l *linter) lintFile(name string, stdin io.Reader) error {
+	r := stdin
+	if name == "-" {
+		name = "<stdin>"
+	} else {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close() // nolint: errcheck
+
+		r = f
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		l.entries++
+		for _, i := range l.lint(line) {
+			if l.ignore[i.code] {
+				continue
+			}
+
+			message := i.message
+			if i.field != "" {
+				message = i.field + ": " + message
+			}
+
+			l.counts[i.code]++
+			fmt.Fprintf(l.out, "%s:%d: %s %s\n", name, n, i.code, message) // nolint: errcheck
+		}
+	}
+
+	return scanner.Err()
+}
+
+// summary writes the number of issues per rule, and returns the exit status.
+func (l *linter) summary() int {
+	total := 0
+	codes := make([]string, 0, len(l.counts))
+	for code, n := range l.counts {
+		codes = append(codes, code)
+		total += n
+	}
+	sort.Strings(codes)
+
+	fmt.Fprintf(l.out, "%d entries checked, %d issues found\n", l.entries, total) // nolint: errcheck
+	for _, code := range codes {
+		fmt.Fprintf(l.out, "  %s  %4d  %s\n", code, l.counts[code], rules[code]) // nolint: errcheck
+	}
+
+	if total > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+// lint returns all issues in a single line.
+func (l *linter) lint(line []byte) []issue {
+	l.issues = nil
+
+	var entry map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+
+	if err := dec.Decode(&entry); err != nil || entry == nil || dec.More() {
+		l.report(ruleInvalidJSON, "", "line is not a JSON object")
+		return l.issues
+	}
+
+	for key, value := range entry {
+		switch key {
+		case "severity":
+			l.severity(key, value)
+		case "timestamp", "time":
+			l.timestamp(key, value)
+		case "httpRequest":
+			l.httpRequest(key, value)
+		case specialPrefix + "labels":
+			l.labels(key, value)
+		case specialPrefix + "sourceLocation":
+			l.sourceLocation(key, value)
+		case specialPrefix + "operation":
+			l.operation(key, value)
+		case specialPrefix + "trace", specialPrefix + "spanId", specialPrefix + "insertId":
+			if _, ok := value.(string); !ok {
+				l.report(ruleTrace, key, "must be a string, got %s", typeOf(value))
+			}
+		case specialPrefix + "trace_sampled":
+			if _, ok := value.(bool); !ok {
+				l.report(ruleTrace, key, "must be a boolean, got %s", typeOf(value))
+			}
+		default:
+			if strings.HasPrefix(key, specialPrefix) && !knownSpecialKeys[key] {
+				l.report(ruleUnknownKey, key, "is not recognized by Cloud Logging")
+			}
+		}
+	}
+
+	sort.Slice(l.issues, func(i, j int) bool {
+		return l.issues[i].field < l.issues[j].field
+	})
+
+	return l.issues
+}
+
+func (l *linter) report(code, field, format string, args ...interface{}) {
+	l.issues = append(l.issues, issue{code: code, field: field, message: fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) severity(key string, value interface{}) {
+	s, ok := value.(string)
+	if !ok {
+		l.report(ruleSeverity, key, "must be a string, got %s", typeOf(value))
+		return
+	}
+
+	if _, ok := zapdriver.SeverityNumber(s); !ok {
+		l.report(ruleSeverity, key, "unknown severity %q", s)
+	}
+}
+
+func (l *linter) timestamp(key string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		if _, err := time.Parse(time.RFC3339Nano, v); err != nil {
+			l.report(ruleTimestamp, key, "%q is not an RFC 3339 timestamp", v)
+		}
+	case map[string]interface{}:
+		if key != "timestamp" {
+			l.report(ruleTimestamp, key, "must be a string, got %s", typeOf(value))
+			return
+		}
+
+		for k, field := range v {
+			if k != "seconds" && k != "nanos" {
+				l.report(ruleTimestamp, key+"."+k, "unknown field")
+			} else if !isInteger(field, 64) {
+				l.report(ruleTimestamp, key+"."+k, "must be an integer, got %s", typeOf(field))
+			}
+		}
+	default:
+		l.report(ruleTimestamp, key, "must be a string, got %s", typeOf(value))
+	}
+}
+
+func (l *linter) labels(key string, value interface{}) {
+	labels, ok := value.(map[string]interface{})
+	if !ok {
+		l.report(ruleLabels, key, "must be an object, got %s", typeOf(value))
+		return
+	}
+
+	for k, v := range labels {
+		if _, ok := v.(string); !ok {
+			l.report(ruleLabels, key+"."+k, "must be a string, got %s", typeOf(v))
+		}
+	}
+}
+
+func (l *linter) httpRequest(key string, value interface{}) {
+	req, ok := value.(map[string]interface{})
+	if !ok {
+		l.report(ruleHTTPRequest, key, "must be an object, got %s", typeOf(value))
+		return
+	}
+
+	for k, v := range req {
+		field := key + "." + k
+
+		switch httpRequestTypes[k] {
+		case "string":
+			if _, ok := v.(string); !ok {
+				l.report(ruleHTTPType, field, "must be a string, got %s", typeOf(v))
+			}
+		case "bool":
+			if _, ok := v.(bool); !ok {
+				l.report(ruleHTTPType, field, "must be a boolean, got %s", typeOf(v))
+			}
+		case "int32":
+			if _, ok := v.(json.Number); !ok || !isInteger(v, 32) {
+				l.report(ruleHTTPType, field, "must be an integer, got %s", describe(v))
+			}
+		case "int64":
+			// 64-bit integers are encoded as strings in JSON, but numbers are
+			// accepted as well.
+			if !isInteger(v, 64) {
+				l.report(ruleHTTPType, field, "must be an integer or a string containing one, got %s", describe(v))
+			}
+		case "duration":
+			if s, ok := v.(string); !ok || !latencyPattern.MatchString(s) {
+				l.report(ruleHTTPLatency, field, "must be a duration such as \"3.5s\", got %s", describe(v))
+			}
+		default:
+			l.report(ruleHTTPRequest, field, "unknown field")
+		}
+	}
+}
+
+func (l *linter) sourceLocation(key string, value interface{}) {
+	source, ok := value.(map[string]interface{})
+	if !ok {
+		l.report(ruleSourceLocation, key, "must be an object, got %s", typeOf(value))
+		return
+	}
+
+	for k, v := range source {
+		field := key + "." + k
+
+		switch k {
+		case "file", "function":
+			if _, ok := v.(string); !ok {
+				l.report(ruleSourceLocation, field, "must be a string, got %s", typeOf(v))
+			}
+		case "line":
+			if !isInteger(v, 64) {
+				l.report(ruleSourceLocation, field, "must be an integer or a string containing one, got %s", describe(v))
+			}
+		default:
+			l.report(ruleSourceLocation, field, "unknown field")
+		}
+	}
+}
+
+func (l *linter) operation(key string, value interface{}) {
+	op, ok := value.(map[string]interface{})
+	if !ok {
+		l.report(ruleOperation, key, "must be an object, got %s", typeOf(value))
+		return
+	}
+
+	for k, v := range op {
+		field := key + "." + k
+
+		switch k {
+		case "id", "producer":
+			if _, ok := v.(string); !ok {
+				l.report(ruleOperation, field, "must be a string, got %s", typeOf(v))
+			}
+		case "first", "last":
+			if _, ok := v.(bool); !ok {
+				l.report(ruleOperation, field, "must be a boolean, got %s", typeOf(v))
+			}
+		default:
+			l.report(ruleOperation, field, "unknown field")
+		}
+	}
+}
+
+// isInteger reports whether v is a JSON number, or a string, containing an
+// integer that fits in the given number of bits.
+func isInteger(v interface{}, bits int) bool {
+	var s string
+	switch v := v.(type) {
+	case json.Number:
+		s = v.String()
+	case string:
+		s = v
+	default:
+		return false
+	}
+
+	_, err := strconv.ParseInt(s, 10, bits)
+	return err == nil
+}
+
+// typeOf returns the JSON type of a decoded value.
+func typeOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// describe returns the JSON type of a decoded value, including the value itself
+// for strings and numbers.
+func describe(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return "string " + strconv.Quote(v)
+	case json.Number:
+		return "number " + v.String()
+	default:
+		return typeOf(v)
+	}
+}
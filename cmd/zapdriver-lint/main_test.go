@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/blendle/zapdriver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLint(t *testing.T) {
+	tests := map[string]struct {
+		line  string
+		codes []string
+	}{
+		"valid": {
+			`{"severity":"INFO","timestamp":"2019-06-01T10:00:00.5Z","message":"ok","logging.googleapis.com/labels":{"a":"b"},"httpRequest":{"requestMethod":"GET","status":200,"requestSize":"12","responseSize":34,"latency":"0.250s","cacheHit":false},"logging.googleapis.com/trace":"projects/p/traces/abc","logging.googleapis.com/trace_sampled":true}`,
+			nil,
+		},
+		"invalid json":       {`not json`, []string{ruleInvalidJSON}},
+		"json array":         {`[1, 2]`, []string{ruleInvalidJSON}},
+		"unknown severity":   {`{"severity":"LOUD"}`, []string{ruleSeverity}},
+		"numeric severity":   {`{"severity":200}`, []string{ruleSeverity}},
+		"bad timestamp":      {`{"timestamp":"yesterday"}`, []string{ruleTimestamp}},
+		"timestamp object":   {`{"timestamp":{"seconds":1559383200,"nanos":5}}`, nil},
+		"numeric label":      {`{"logging.googleapis.com/labels":{"a":"b","n":1}}`, []string{ruleLabels}},
+		"labels array":       {`{"logging.googleapis.com/labels":["a"]}`, []string{ruleLabels}},
+		"latency as number":  {`{"httpRequest":{"latency":0.25}}`, []string{ruleHTTPLatency}},
+		"latency go format":  {`{"httpRequest":{"latency":"250ms"}}`, []string{ruleHTTPLatency}},
+		"string status":      {`{"httpRequest":{"status":"200"}}`, []string{ruleHTTPType}},
+		"empty size":         {`{"httpRequest":{"requestSize":""}}`, []string{ruleHTTPType}},
+		"unknown http field": {`{"httpRequest":{"verb":"GET"}}`, []string{ruleHTTPRequest}},
+		"source line":        {`{"logging.googleapis.com/sourceLocation":{"file":"a.go","line":"twelve"}}`, []string{ruleSourceLocation}},
+		"operation first":    {`{"logging.googleapis.com/operation":{"id":"1","first":"yes"}}`, []string{ruleOperation}},
+		"trace sampled":      {`{"logging.googleapis.com/trace_sampled":"true"}`, []string{ruleTrace}},
+		"numeric span":       {`{"logging.googleapis.com/spanId":123}`, []string{ruleTrace}},
+		"unknown special":    {`{"logging.googleapis.com/label":{"a":"b"}}`, []string{ruleUnknownKey}},
+		"multiple": {
+			`{"severity":"LOUD","httpRequest":{"status":1.5,"latency":"1m"}}`,
+			[]string{ruleHTTPLatency, ruleHTTPType, ruleSeverity},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			l := &linter{}
+
+			var codes []string
+			for _, i := range l.lint([]byte(tt.line)) {
+				codes = append(codes, i.code)
+			}
+
+			assert.Equal(t, tt.codes, codes)
+		})
+	}
+}
+
+func TestLint_ZapdriverOutput(t *testing.T) {
+	buf := &bytes.Buffer{}
+	encoder := zapcore.NewJSONEncoder(zapdriver.NewProductionEncoderConfig())
+	logger := zap.New(zapcore.NewCore(encoder, zapcore.AddSync(buf), zapcore.DebugLevel), zap.AddCaller(), zapdriver.WrapCore())
+
+	logger.Info("hello", append(zapdriver.TraceContext("105445aa7843bc8bf206b120001000", "0020000f", true, "p"), zapdriver.Label("a", "b"))...)
+	logger.Error("oops", zapdriver.OperationStart("id", "producer"))
+
+	var out bytes.Buffer
+	assert.Equal(t, 0, run(nil, buf, &out, &bytes.Buffer{}), out.String())
+}
+
+func TestRun(t *testing.T) {
+	input := strings.Join([]string{
+		`{"severity":"INFO","message":"ok"}`,
+		``,
+		`{"severity":"LOUD","logging.googleapis.com/foo":1}`,
+		`oops`,
+	}, "\n")
+
+	var out, errOut bytes.Buffer
+	code := run(nil, strings.NewReader(input), &out, &errOut)
+
+	assert.Equal(t, 1, code)
+	assert.Equal(t, strings.Join([]string{
+		`<stdin>:3: ZD011 logging.googleapis.com/foo: is not recognized by Cloud Logging`,
+		`<stdin>:3: ZD002 severity: unknown severity "LOUD"`,
+		`<stdin>:4: ZD001 line is not a JSON object`,
+		`3 entries checked, 3 issues found`,
+		`  ZD001     1  line is not a JSON object`,
+		`  ZD002     1  severity is not a known LogSeverity`,
+		`  ZD011     1  unknown logging.googleapis.com/ field`,
+		``,
+	}, "\n"), out.String())
+	assert.Empty(t, errOut.String())
+}
+
+func TestRun_Ignore(t *testing.T) {
+	var out bytes.Buffer
+	code := run([]string{"-ignore", "ZD002, ZD011"}, strings.NewReader(`{"severity":"LOUD"}`), &out, &bytes.Buffer{})
+
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "1 entries checked, 0 issues found\n", out.String())
+}
+
+func TestRun_Rules(t *testing.T) {
+	var out bytes.Buffer
+	require.Equal(t, 0, run([]string{"-rules"}, nil, &out, &bytes.Buffer{}))
+
+	assert.Len(t, strings.Split(strings.TrimSpace(out.String()), "\n"), len(rules))
+	assert.True(t, strings.HasPrefix(out.String(), "ZD001  line is not a JSON object\n"))
+}
+
+func TestRun_InvalidFlags(t *testing.T) {
+	var errOut bytes.Buffer
+
+	assert.Equal(t, 2, run([]string{"-ignore", "ZD999"}, nil, &bytes.Buffer{}, &errOut))
+	assert.Contains(t, errOut.String(), "unknown rule")
+
+	errOut.Reset()
+	assert.Equal(t, 2, run([]string{"does-not-exist.log"}, nil, &bytes.Buffer{}, &errOut))
+	assert.Contains(t, errOut.String(), "does-not-exist.log")
+}
@@ -0,0 +1,287 @@
+// Command zapdriver-pretty reads newline-delimited Zapdriver JSON logs from
+// standard input, and writes them to standard output in a compact,
+// human-readable format.
+//
+// Lines that are not JSON log entries are written as-is.
+//
+// Usage:
+//
+//	kubectl logs my-pod | zapdriver-pretty -severity WARNING -label tenant=acme
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blendle/zapdriver"
+)
+
+const (
+	colorReset = "\x1b[0m"
+	colorDim   = "\x1b[2m"
+	colorCyan  = "\x1b[36m"
+)
+
+// severityColors maps severities to the ANSI colors they are rendered with.
+var severityColors = map[string]string{
+	"DEBUG":     "\x1b[90m",
+	"INFO":      "\x1b[32m",
+	"NOTICE":    "\x1b[36m",
+	"WARNING":   "\x1b[33m",
+	"ERROR":     "\x1b[31m",
+	"CRITICAL":  "\x1b[1;31m",
+	"ALERT":     "\x1b[1;35m",
+	"EMERGENCY": "\x1b[1;35m",
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// labelFlags collects repeated `-label key=value` flags.
+type labelFlags map[string]string
+
+func (l labelFlags) String() string {
+	pairs := make([]string, 0, len(l))
+	for k, v := range l {
+		pairs = append(pairs, k+"="+v)
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (l labelFlags) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i < 1 {
+		return errors.New("label must be formatted as key=value")
+	}
+
+	l[s[:i]] = s[i+1:]
+	return nil
+}
+
+// printer renders log entries.
+type printer struct {
+	out   *bufio.Writer
+	color bool
+	utc   bool
+
+	minSeverity int
+	labels      labelFlags
+	trace       string
+}
+
+func run(args []string, in io.Reader, out, errOut io.Writer) int {
+	p := &printer{labels: labelFlags{}}
+
+	fs := flag.NewFlagSet("zapdriver-pretty", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	severity := fs.String("severity", "", "only show entries with at least this `severity`, such as WARNING")
+	color := fs.String("color", "auto", "colorize the output: auto, always or never")
+	fs.Var(p.labels, "label", "only show entries with this `key=value` label, can be repeated")
+	fs.StringVar(&p.trace, "trace", "", "only show entries belonging to this trace `ID`")
+	fs.BoolVar(&p.utc, "utc", false, "show timestamps in UTC instead of local time")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *severity != "" {
+		n, ok := zapdriver.SeverityNumber(strings.ToUpper(*severity))
+		if !ok {
+			fmt.Fprintf(errOut, "zapdriver-pretty: unknown severity %q\n", *severity) // nolint: errcheck
+			return 2
+		}
+		p.minSeverity = n
+	}
+
+	switch *color {
+	case "always":
+		p.color = true
+	case "never":
+		p.color = false
+	case "auto":
+		p.color = isTerminal(out)
+	default:
+		fmt.Fprintf(errOut, "zapdriver-pretty: invalid color mode %q\n", *color) // nolint: errcheck
+		return 2
+	}
+
+	p.out = bufio.NewWriter(out)
+	defer p.out.Flush() // nolint: errcheck
+
+	dec := zapdriver.NewLogEntryDecoder(in)
+	for {
+		var entry zapdriver.LogEntry
+		err := dec.Decode(&entry)
+
+		switch e := err.(type) {
+		case nil:
+			if p.match(&entry) {
+				p.print(&entry)
+			}
+		case *zapdriver.DecodeError:
+			p.out.Write(e.Line)
+			p.out.WriteByte('\n')
+		default:
+			if err == io.EOF {
+				return 0
+			}
+
+			p.out.Flush()                                      // nolint: errcheck
+			fmt.Fprintf(errOut, "zapdriver-pretty: %v\n", err) // nolint: errcheck
+			return 1
+		}
+
+		// Flush after every entry, so the output keeps up with `tail -f`.
+		p.out.Flush() // nolint: errcheck
+	}
+}
+
+func (p *printer) match(e *zapdriver.LogEntry) bool {
+	if n, _ := zapdriver.SeverityNumber(e.Severity); n < p.minSeverity {
+		return false
+	}
+
+	for k, v := range p.labels {
+		if e.Labels[k] != v {
+			return false
+		}
+	}
+
+	if p.trace != "" && e.Trace != p.trace && !strings.HasSuffix(e.Trace, "/traces/"+p.trace) {
+		return false
+	}
+
+	return true
+}
+
+func (p *printer) print(e *zapdriver.LogEntry) {
+	ts := e.Timestamp.Local()
+	if p.utc {
+		ts = e.Timestamp.UTC()
+	}
+
+	p.write(colorDim, ts.Format("2006-01-02 15:04:05.000"))
+	p.out.WriteByte(' ')
+
+	severity := e.Severity
+	if severity == "" {
+		severity = "DEFAULT"
+	}
+	p.write(severityColors[severity], fmt.Sprintf("%-9s", severity))
+	p.out.WriteByte(' ')
+	p.out.WriteString(e.Message)
+
+	if r := e.HTTPRequest; r != nil {
+		p.out.WriteString("  " + httpLine(r))
+	}
+
+	var stacktrace string
+	keys := make([]string, 0, len(e.Payload))
+	for k := range e.Payload {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		switch k {
+		case "caller":
+			// Already shown as the source location.
+			continue
+		case "stacktrace":
+			stacktrace, _ = e.Payload[k].(string)
+			continue
+		}
+
+		p.out.WriteString(" ")
+		p.write(colorDim, k+"=")
+		p.out.WriteString(fmt.Sprintf("%v", e.Payload[k]))
+	}
+
+	if len(e.Labels) > 0 {
+		pairs := make([]string, 0, len(e.Labels))
+		for k, v := range e.Labels {
+			pairs = append(pairs, k+"="+v)
+		}
+		sort.Strings(pairs)
+
+		p.out.WriteString(" ")
+		p.write(colorCyan, "{"+strings.Join(pairs, " ")+"}")
+	}
+
+	if e.Trace != "" {
+		trace := e.Trace[strings.LastIndex(e.Trace, "/")+1:]
+		if e.SpanID != "" {
+			trace += "/" + e.SpanID
+		}
+
+		p.out.WriteString(" ")
+		p.write(colorDim, "trace="+trace)
+	}
+
+	if s := e.SourceLocation; s != nil {
+		p.out.WriteString(" ")
+		p.write(colorDim, "("+filepath.Base(s.File)+":"+s.Line+")")
+	}
+
+	p.out.WriteByte('\n')
+
+	if stacktrace != "" {
+		for _, line := range strings.Split(stacktrace, "\n") {
+			p.write(colorDim, "    "+line)
+			p.out.WriteByte('\n')
+		}
+	}
+}
+
+// write writes s, wrapped in the given ANSI color if colors are enabled.
+func (p *printer) write(color, s string) {
+	if p.color && color != "" {
+		s = color + s + colorReset
+	}
+
+	p.out.WriteString(s)
+}
+
+// httpLine renders an HTTP request as a single access log line.
+func httpLine(r *zapdriver.HTTPPayload) string {
+	parts := []string{}
+	for _, s := range []string{r.RequestMethod, r.RequestURL} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+
+	if r.Status != 0 {
+		parts = append(parts, fmt.Sprint(r.Status))
+	}
+
+	if d, err := time.ParseDuration(r.Latency); err == nil {
+		parts = append(parts, d.String())
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const input = `{"severity":"INFO","timestamp":"2019-06-01T10:00:00.5Z","message":"hello","user":"alice","caller":"main.go:12","logging.googleapis.com/labels":{"tenant":"acme"},"logging.googleapis.com/sourceLocation":{"file":"/src/app/main.go","line":"12","function":"main.main"}}
+not json at all
+{"severity":"ERROR","timestamp":"2019-06-01T10:00:01Z","message":"request failed","httpRequest":{"requestMethod":"GET","requestUrl":"/foo","status":500,"latency":"0.250000000s"},"logging.googleapis.com/trace":"projects/p/traces/abc123","logging.googleapis.com/spanId":"def","stacktrace":"main.main\n\t/src/app/main.go:20"}
+{"severity":"DEBUG","timestamp":"2019-06-01T10:00:02Z","message":"noise","logging.googleapis.com/labels":{"tenant":"other"}}
+`
+
+func pretty(t *testing.T, args ...string) string {
+	t.Helper()
+
+	var out, errOut bytes.Buffer
+	code := run(append([]string{"-color", "never", "-utc"}, args...), strings.NewReader(input), &out, &errOut)
+	require.Equal(t, 0, code, errOut.String())
+
+	return out.String()
+}
+
+func TestRun(t *testing.T) {
+	want := strings.Join([]string{
+		`2019-06-01 10:00:00.500 INFO      hello user=alice {tenant=acme} (main.go:12)`,
+		`not json at all`,
+		`2019-06-01 10:00:01.000 ERROR     request failed  GET /foo 500 250ms trace=abc123/def`,
+		`    main.main`,
+		`    	/src/app/main.go:20`,
+		`2019-06-01 10:00:02.000 DEBUG     noise {tenant=other}`,
+		``,
+	}, "\n")
+
+	assert.Equal(t, want, pretty(t))
+}
+
+func TestRun_Filters(t *testing.T) {
+	t.Run("severity", func(t *testing.T) {
+		out := pretty(t, "-severity", "info")
+
+		assert.Contains(t, out, "hello")
+		assert.Contains(t, out, "request failed")
+		assert.NotContains(t, out, "noise")
+		assert.Contains(t, out, "not json at all")
+	})
+
+	t.Run("label", func(t *testing.T) {
+		out := pretty(t, "-label", "tenant=acme")
+
+		assert.Contains(t, out, "hello")
+		assert.NotContains(t, out, "request failed")
+		assert.NotContains(t, out, "noise")
+	})
+
+	t.Run("trace", func(t *testing.T) {
+		for _, trace := range []string{"abc123", "projects/p/traces/abc123"} {
+			out := pretty(t, "-trace", trace)
+
+			assert.NotContains(t, out, "hello")
+			assert.Contains(t, out, "request failed")
+		}
+	})
+}
+
+func TestRun_Color(t *testing.T) {
+	var out bytes.Buffer
+	code := run([]string{"-color", "always", "-severity", "ERROR"}, strings.NewReader(input), &out, &bytes.Buffer{})
+
+	require.Equal(t, 0, code)
+	assert.Contains(t, out.String(), "\x1b[31mERROR    \x1b[0m")
+}
+
+func TestRun_InvalidFlags(t *testing.T) {
+	tests := [][]string{
+		{"-severity", "LOUD"},
+		{"-color", "sometimes"},
+		{"-label", "novalue"},
+	}
+
+	for _, args := range tests {
+		var errOut bytes.Buffer
+
+		assert.Equal(t, 2, run(args, strings.NewReader(""), &bytes.Buffer{}, &errOut), args)
+		assert.NotEmpty(t, errOut.String(), args)
+	}
+}
@@ -1,11 +1,23 @@
 package zapdriver_test
 
 import (
+	"io"
 	"time"
 
+	"github.com/blendle/zapdriver"
+	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
+// zapdriverLogger returns a logger with the Zapdriver encoder and core, writing
+// DebugLevel and above logs to w.
+func zapdriverLogger(w io.Writer) *zap.Logger {
+	encoder := zapcore.NewJSONEncoder(zapdriver.NewProductionEncoderConfig())
+	core := zapcore.NewCore(encoder, zapcore.AddSync(w), zapcore.DebugLevel)
+
+	return zap.New(core, zapdriver.WrapCore(), zap.AddCaller())
+}
+
 // sliceArrayEncoder is an ArrayEncoder backed by a simple []interface{}. Like
 // the MapObjectEncoder, it's not designed for production use.
 type sliceArrayEncoder struct {
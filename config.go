@@ -1,6 +1,14 @@
 package zapdriver
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -53,3 +61,271 @@ func NewDevelopmentConfig() zap.Config {
 		ErrorOutputPaths: []string{"stderr"},
 	}
 }
+
+// Config is a superset of zap.Config, with the settings of the zapdriver core.
+// Like zap.Config, it can be decoded from JSON or YAML, and built into a logger
+// with the zapdriver core enabled.
+type Config struct {
+	zap.Config `yaml:",inline"`
+
+	// ServiceName and ServiceVersion are added as `ServiceContext()` to all
+	// logs when set.
+	ServiceName    string `json:"serviceName" yaml:"serviceName"`
+	ServiceVersion string `json:"serviceVersion" yaml:"serviceVersion"`
+
+	// ReportAllErrors reports all logs with level error or above to
+	// Stackdriver Error Reporting.
+	ReportAllErrors bool `json:"reportAllErrors" yaml:"reportAllErrors"`
+
+	// ProjectID is used to expand trace IDs to the full trace resource name.
+	ProjectID string `json:"projectId" yaml:"projectId"`
+
+	// SamplingRules are added to the trace-aware sampler that replaces the
+	// sampler of zap. See `NewSampler()`.
+	SamplingRules []SamplingRule `json:"samplingRules" yaml:"samplingRules"`
+
+	// MaxLabels and MaxLabelValueLength limit the labels of all logs. See
+	// `LabelLimits()`.
+	MaxLabels           int `json:"maxLabels" yaml:"maxLabels"`
+	MaxLabelValueLength int `json:"maxLabelValueLength" yaml:"maxLabelValueLength"`
+
+	// Redaction configures the redaction of sensitive values when set.
+	Redaction *RedactionConfig `json:"redaction" yaml:"redaction"`
+
+	// Resource is added as labels to all logs when set. See
+	// `ResourceLabels()`.
+	Resource *Resource `json:"resource" yaml:"resource"`
+
+	// SyncAfterWrite syncs the output after every log. See
+	// `SyncAfterWrite()`.
+	SyncAfterWrite bool `json:"syncAfterWrite" yaml:"syncAfterWrite"`
+
+	// KeepZeroFields keeps the unset fields of the special purpose fields. See
+	// `KeepZeroFields()`.
+	KeepZeroFields bool `json:"keepZeroFields" yaml:"keepZeroFields"`
+
+	// TrimSourcePrefix is trimmed from the file paths of source locations. See
+	// `TrimSourcePrefix()`.
+	TrimSourcePrefix string `json:"trimSourcePrefix" yaml:"trimSourcePrefix"`
+
+	// TrimSourceModule writes the file paths of the main module relative to
+	// the module root. See `TrimSourceModule()`.
+	TrimSourceModule bool `json:"trimSourceModule" yaml:"trimSourceModule"`
+
+	// ShortFunctionNames writes function names without their package
+	// directory. See `ShortFunctionNames()`.
+	ShortFunctionNames bool `json:"shortFunctionNames" yaml:"shortFunctionNames"`
+
+	// IgnoreCallerPackages are skipped when looking up the caller of a log.
+	// See `IgnoreCallerPackages()`.
+	IgnoreCallerPackages []string `json:"ignoreCallerPackages" yaml:"ignoreCallerPackages"`
+}
+
+// RedactionConfig configures the redaction of sensitive values. See
+// `NewRedactor()`.
+type RedactionConfig struct {
+	Keys   []string `json:"keys" yaml:"keys"`
+	Values []string `json:"values" yaml:"values"`
+	Hash   bool     `json:"hash" yaml:"hash"`
+}
+
+// NewConfig returns a Config based on `NewProductionConfig()`.
+func NewConfig() Config {
+	return Config{Config: NewProductionConfig()}
+}
+
+// LoadConfig reads a JSON encoded Config from the file at path. Settings that
+// are not in the file keep the values of `NewConfig()`.
+func LoadConfig(path string) (Config, error) {
+	cfg := NewConfig()
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, fmt.Errorf("zapdriver: invalid config file %s: %v", path, err)
+	}
+
+	return cfg, nil
+}
+
+// ConfigFromEnv returns a Config based on `NewConfig()`, or on the file set in
+// `ZAPDRIVER_CONFIG`, with the settings of the following environment variables
+// applied:
+//
+//	ZAPDRIVER_LEVEL                   minimum level, such as "debug"
+//	ZAPDRIVER_DEVELOPMENT             development mode, "true" or "false"
+//	ZAPDRIVER_OUTPUT                  comma-separated output paths, such as "stdout"
+//	ZAPDRIVER_SERVICE_NAME            service name
+//	ZAPDRIVER_SERVICE_VERSION         service version
+//	ZAPDRIVER_REPORT_ALL_ERRORS       report all errors, "true" or "false"
+//	ZAPDRIVER_PROJECT_ID              project ID
+//	ZAPDRIVER_SAMPLING                "off", or the initial and thereafter values, such as "100,100"
+//	ZAPDRIVER_MAX_LABELS              maximum number of labels
+//	ZAPDRIVER_MAX_LABEL_VALUE_LENGTH  maximum length of label values
+//	ZAPDRIVER_REDACT_KEYS             comma-separated key patterns to redact
+//	ZAPDRIVER_REDACT_VALUES           comma-separated value patterns to redact
+//	ZAPDRIVER_REDACT_HASH             hash redacted values, "true" or "false"
+func ConfigFromEnv() (Config, error) {
+	return configFromEnv(os.Getenv)
+}
+
+func configFromEnv(getenv func(string) string) (Config, error) {
+	cfg := NewConfig()
+	if path := getenv("ZAPDRIVER_CONFIG"); path != "" {
+		var err error
+		if cfg, err = LoadConfig(path); err != nil {
+			return cfg, err
+		}
+	}
+
+	return cfg, cfg.applyEnv(getenv)
+}
+
+// applyEnv applies the settings of the `ZAPDRIVER_*` environment variables,
+// except `ZAPDRIVER_CONFIG`, to the config.
+func (cfg *Config) applyEnv(getenv func(string) string) error {
+	env := &envParser{getenv: getenv}
+
+	if v := getenv("ZAPDRIVER_LEVEL"); v != "" {
+		if err := cfg.Level.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("zapdriver: invalid ZAPDRIVER_LEVEL: %v", err)
+		}
+	}
+	env.bool("ZAPDRIVER_DEVELOPMENT", &cfg.Development)
+	if v := getenv("ZAPDRIVER_OUTPUT"); v != "" {
+		cfg.OutputPaths = strings.Split(v, ",")
+	}
+
+	env.string("ZAPDRIVER_SERVICE_NAME", &cfg.ServiceName)
+	env.string("ZAPDRIVER_SERVICE_VERSION", &cfg.ServiceVersion)
+	env.bool("ZAPDRIVER_REPORT_ALL_ERRORS", &cfg.ReportAllErrors)
+	env.string("ZAPDRIVER_PROJECT_ID", &cfg.ProjectID)
+
+	switch v := getenv("ZAPDRIVER_SAMPLING"); v {
+	case "":
+	case "off", "false":
+		cfg.Sampling = nil
+	default:
+		parts := strings.Split(v, ",")
+		initial, err1 := strconv.Atoi(parts[0])
+		thereafter, err2 := strconv.Atoi(parts[len(parts)-1])
+		if len(parts) != 2 || err1 != nil || err2 != nil {
+			return fmt.Errorf("zapdriver: invalid ZAPDRIVER_SAMPLING %q", v)
+		}
+
+		cfg.Sampling = &zap.SamplingConfig{Initial: initial, Thereafter: thereafter}
+	}
+
+	env.int("ZAPDRIVER_MAX_LABELS", &cfg.MaxLabels)
+	env.int("ZAPDRIVER_MAX_LABEL_VALUE_LENGTH", &cfg.MaxLabelValueLength)
+
+	keys, values := getenv("ZAPDRIVER_REDACT_KEYS"), getenv("ZAPDRIVER_REDACT_VALUES")
+	if keys != "" || values != "" || getenv("ZAPDRIVER_REDACT_HASH") != "" {
+		if cfg.Redaction == nil {
+			cfg.Redaction = &RedactionConfig{}
+		}
+		if keys != "" {
+			cfg.Redaction.Keys = strings.Split(keys, ",")
+		}
+		if values != "" {
+			cfg.Redaction.Values = strings.Split(values, ",")
+		}
+		env.bool("ZAPDRIVER_REDACT_HASH", &cfg.Redaction.Hash)
+	}
+
+	return env.err
+}
+
+// envParser parses environment variables, and keeps the first error.
+type envParser struct {
+	getenv func(string) string
+	err    error
+}
+
+func (e *envParser) string(key string, dst *string) {
+	if v := e.getenv(key); v != "" {
+		*dst = v
+	}
+}
+
+func (e *envParser) bool(key string, dst *bool) {
+	v := e.getenv(key)
+	if v == "" {
+		return
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil && e.err == nil {
+		e.err = fmt.Errorf("zapdriver: invalid %s %q", key, v)
+	}
+	*dst = b
+}
+
+func (e *envParser) int(key string, dst *int) {
+	v := e.getenv(key)
+	if v == "" {
+		return
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil && e.err == nil {
+		e.err = fmt.Errorf("zapdriver: invalid %s %q", key, v)
+	}
+	*dst = n
+}
+
+// CoreOptions returns the zapdriver core options for the settings of the
+// config, to be passed to `WrapCore()`.
+func (cfg Config) CoreOptions() ([]func(*core), error) {
+	options := []func(*core){
+		ReportAllErrors(cfg.ReportAllErrors),
+		ServiceName(cfg.ServiceName),
+		ServiceVersion(cfg.ServiceVersion),
+		ProjectID(cfg.ProjectID),
+		LabelLimits(cfg.MaxLabels, cfg.MaxLabelValueLength),
+		ResourceLabels(cfg.Resource),
+		SyncAfterWrite(cfg.SyncAfterWrite),
+		KeepZeroFields(cfg.KeepZeroFields),
+		TrimSourcePrefix(cfg.TrimSourcePrefix),
+		TrimSourceModule(cfg.TrimSourceModule),
+		ShortFunctionNames(cfg.ShortFunctionNames),
+		IgnoreCallerPackages(cfg.IgnoreCallerPackages...),
+	}
+
+	if cfg.Sampling != nil {
+		options = append(options, Sampling(NewSampler(time.Second, cfg.Sampling.Initial, cfg.Sampling.Thereafter, cfg.SamplingRules...)))
+	} else if len(cfg.SamplingRules) > 0 {
+		// Keep all entries that don't match any of the rules.
+		options = append(options, Sampling(NewSampler(time.Second, 1, 1, cfg.SamplingRules...)))
+	}
+
+	if r := cfg.Redaction; r != nil {
+		redactor, err := NewRedactor(RedactKeys(r.Keys...), RedactValues(r.Values...), RedactHash(r.Hash))
+		if err != nil {
+			return nil, err
+		}
+
+		options = append(options, Redact(redactor))
+	}
+
+	return options, nil
+}
+
+// Build constructs a logger from the Config, with the zapdriver core enabled.
+//
+// The sampler of zap is replaced by the trace-aware sampler of zapdriver, which
+// never drops entries that are part of a sampled trace.
+func (cfg Config) Build(options ...zap.Option) (*zap.Logger, error) {
+	coreOptions, err := cfg.CoreOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	zapConfig := cfg.Config
+	zapConfig.Sampling = nil
+
+	return zapConfig.Build(append(options, WrapCore(coreOptions...))...)
+}
@@ -0,0 +1,136 @@
+package zapdriver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zapdriver")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	path := filepath.Join(dir, "config.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{
+		"level": "warn",
+		"outputPaths": ["stdout"],
+		"serviceName": "service",
+		"serviceVersion": "v1.2.3",
+		"reportAllErrors": true,
+		"samplingRules": [{"name": "keep", "levels": ["error"], "keep": true}],
+		"redaction": {"keys": ["password"]}
+	}`), 0600))
+
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, zapcore.WarnLevel, cfg.Level.Level())
+	assert.Equal(t, []string{"stdout"}, cfg.OutputPaths)
+	assert.Equal(t, "json", cfg.Encoding, "defaults are kept")
+	assert.Equal(t, "severity", cfg.EncoderConfig.LevelKey, "defaults are kept")
+	assert.Equal(t, "service", cfg.ServiceName)
+	assert.Equal(t, "v1.2.3", cfg.ServiceVersion)
+	assert.True(t, cfg.ReportAllErrors)
+	assert.Equal(t, []SamplingRule{{Name: "keep", Levels: []zapcore.Level{zapcore.ErrorLevel}, Keep: true}}, cfg.SamplingRules)
+	assert.Equal(t, &RedactionConfig{Keys: []string{"password"}}, cfg.Redaction)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"level": 12}`), 0600))
+	_, err = LoadConfig(path)
+	assert.Error(t, err)
+
+	_, err = LoadConfig(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	env := map[string]string{
+		"ZAPDRIVER_LEVEL":                  "debug",
+		"ZAPDRIVER_OUTPUT":                 "stdout,/tmp/log",
+		"ZAPDRIVER_SERVICE_NAME":           "service",
+		"ZAPDRIVER_SERVICE_VERSION":        "abc123",
+		"ZAPDRIVER_REPORT_ALL_ERRORS":      "true",
+		"ZAPDRIVER_PROJECT_ID":             "project",
+		"ZAPDRIVER_SAMPLING":               "10,1000",
+		"ZAPDRIVER_MAX_LABELS":             "32",
+		"ZAPDRIVER_MAX_LABEL_VALUE_LENGTH": "1024",
+		"ZAPDRIVER_REDACT_KEYS":            "password,token",
+		"ZAPDRIVER_REDACT_HASH":            "1",
+	}
+
+	cfg, err := configFromEnv(func(key string) string { return env[key] })
+	require.NoError(t, err)
+
+	assert.Equal(t, zapcore.DebugLevel, cfg.Level.Level())
+	assert.Equal(t, []string{"stdout", "/tmp/log"}, cfg.OutputPaths)
+	assert.Equal(t, "service", cfg.ServiceName)
+	assert.Equal(t, "abc123", cfg.ServiceVersion)
+	assert.True(t, cfg.ReportAllErrors)
+	assert.Equal(t, "project", cfg.ProjectID)
+	assert.Equal(t, &zap.SamplingConfig{Initial: 10, Thereafter: 1000}, cfg.Sampling)
+	assert.Equal(t, 32, cfg.MaxLabels)
+	assert.Equal(t, 1024, cfg.MaxLabelValueLength)
+	assert.Equal(t, &RedactionConfig{Keys: []string{"password", "token"}, Hash: true}, cfg.Redaction)
+}
+
+func TestConfigFromEnv_Defaults(t *testing.T) {
+	cfg, err := configFromEnv(func(string) string { return "" })
+	require.NoError(t, err)
+
+	assert.Equal(t, zapcore.InfoLevel, cfg.Level.Level())
+	assert.Equal(t, &zap.SamplingConfig{Initial: 100, Thereafter: 100}, cfg.Sampling)
+	assert.Nil(t, cfg.Redaction)
+
+	cfg, err = configFromEnv(func(key string) string {
+		return map[string]string{"ZAPDRIVER_SAMPLING": "off"}[key]
+	})
+	require.NoError(t, err)
+	assert.Nil(t, cfg.Sampling)
+}
+
+func TestConfigFromEnv_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"ZAPDRIVER_LEVEL":             "loud",
+		"ZAPDRIVER_SAMPLING":          "100",
+		"ZAPDRIVER_REPORT_ALL_ERRORS": "sometimes",
+		"ZAPDRIVER_MAX_LABELS":        "many",
+		"ZAPDRIVER_CONFIG":            "/does/not/exist.json",
+	}
+
+	for key, value := range tests {
+		_, err := configFromEnv(func(k string) string {
+			if k == key {
+				return value
+			}
+			return ""
+		})
+
+		assert.Error(t, err, key)
+	}
+}
+
+func TestConfig_Build(t *testing.T) {
+	cfg := NewConfig()
+	cfg.OutputPaths = []string{"stdout"}
+	cfg.ServiceName = "service"
+	cfg.Redaction = &RedactionConfig{Keys: []string{"("}}
+
+	_, err := cfg.Build()
+	assert.Error(t, err)
+
+	cfg.Redaction.Keys = []string{"password"}
+	logger, err := cfg.Build()
+	require.NoError(t, err)
+
+	c, ok := logger.Core().(*core)
+	require.True(t, ok)
+	assert.Equal(t, "service", c.config.ServiceName)
+	assert.NotNil(t, c.config.Redactor)
+	assert.NotNil(t, c.config.Sampler, "zap sampling is replaced")
+}
@@ -1,7 +1,10 @@
 package zapdriver
 
 import (
+	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -15,6 +18,56 @@ type driverConfig struct {
 
 	// ServiceName is added as `ServiceContext()` to all logs when set
 	ServiceName string
+
+	// ServiceVersion is added to the `ServiceContext()` of all logs when set
+	ServiceVersion string
+
+	// ProjectID is used to expand trace IDs to the full trace resource name
+	// when set
+	ProjectID string
+
+	// MaxLabels and MaxLabelValueLength limit the number of labels and the
+	// length of label values of all logs when set
+	MaxLabels           int
+	MaxLabelValueLength int
+
+	// Resource is added as labels to all logs when set
+	Resource *Resource
+
+	// InsertIDGenerator is used to add an `InsertID()` to all logs when set
+	InsertIDGenerator func() string
+
+	// ReportLimiter is used to limit the number of identical errors reported
+	// to Error Reporting when set
+	ReportLimiter *reportLimiter
+
+	// Redactor is used to redact sensitive values from all logs when set
+	Redactor *Redactor
+
+	// Levels overrides the level of loggers by their name when set
+	Levels *LevelRegistry
+
+	// SyncAfterWrite syncs the wrapped core after every log when set
+	SyncAfterWrite bool
+
+	// Sampler is used to sample all logs that are not part of a sampled trace
+	// when set
+	Sampler *Sampler
+
+	// KeepZeroFields keeps the unset fields of the special purpose fields when
+	// set
+	KeepZeroFields bool
+
+	// SourceFormat formats the file paths and function names of the source
+	// locations and error reports added to all logs
+	SourceFormat sourceFormat
+
+	// SourceReference is added to the error reports of all logs when set
+	SourceReference *sourceReference
+
+	// IgnoredPackages are skipped when looking up the caller of all logs for
+	// their source location and error report when set
+	IgnoredPackages []string
 }
 
 // Core is a zapdriver specific core wrapped around the default zap core. It
@@ -38,6 +91,10 @@ type core struct {
 	// Zap core.
 	tempLabels *labels
 
+	// sampledTrace is set when the fields added through the use of `With()`
+	// include a sampled trace, in which case entries are never sampled.
+	sampledTrace bool
+
 	// Configuration for the zapdriver core
 	config driverConfig
 }
@@ -58,6 +115,166 @@ func ServiceName(name string) func(*core) {
 	}
 }
 
+// zapdriver core option to add `version` to the `ServiceContext()` of all logs
+func ServiceVersion(version string) func(*core) {
+	return func(c *core) {
+		c.config.ServiceVersion = version
+	}
+}
+
+// zapdriver core option to expand trace fields that only contain a trace ID to
+// the full `projects/<projectID>/traces/<traceID>` resource name expected by
+// Stackdriver
+func ProjectID(projectID string) func(*core) {
+	return func(c *core) {
+		c.config.ProjectID = projectID
+	}
+}
+
+// zapdriver core option to limit the number of labels of all logs to
+// `maxLabels`, and the length of label values to `maxValueLength` bytes.
+// Values that are too long are truncated, and labels beyond the limit are
+// dropped in alphabetical order. A limit of zero disables that limit
+func LabelLimits(maxLabels, maxValueLength int) func(*core) {
+	return func(c *core) {
+		c.config.MaxLabels = maxLabels
+		c.config.MaxLabelValueLength = maxValueLength
+	}
+}
+
+// zapdriver core option to add the type and labels of the monitored resource
+// as labels to all logs, prefixed with `resource.`
+func ResourceLabels(resource *Resource) func(*core) {
+	return func(c *core) {
+		c.config.Resource = resource
+	}
+}
+
+// zapdriver core option to add an `InsertID()` to all logs, using `generator`
+// to generate the IDs. If `generator` is nil, the generator returned by
+// `NewInsertIDGenerator()` is used
+func GenerateInsertIDs(generator func() string) func(*core) {
+	if generator == nil {
+		generator = NewInsertIDGenerator()
+	}
+
+	return func(c *core) {
+		c.config.InsertIDGenerator = generator
+	}
+}
+
+// zapdriver core option to report each error to Error Reporting at most
+// `limit` times per `window`. Errors are identical when they have the same
+// message, report location and error type. Further occurrences are logged
+// without the `context` field but with a `suppressed_count`, and an aggregate
+// entry of all suppressed errors is logged after each window. The aggregate is
+// written lazily, before the next reported error, or when the logger is synced,
+// which also ends the window
+func ErrorReportLimit(limit int, window time.Duration) func(*core) {
+	return func(c *core) {
+		c.config.ReportLimiter = newReportLimiter(limit, window)
+	}
+}
+
+// zapdriver core option to redact sensitive values from all fields and labels
+// using `redactor`, before they are encoded
+func Redact(redactor *Redactor) func(*core) {
+	return func(c *core) {
+		c.config.Redactor = redactor
+	}
+}
+
+// zapdriver core option to override the level of loggers by their name, using
+// the levels in `registry`
+func LevelOverrides(registry *LevelRegistry) func(*core) {
+	return func(c *core) {
+		c.config.Levels = registry
+	}
+}
+
+// zapdriver core option to sample all logs using `sampler`, except those that
+// are part of a sampled trace
+func Sampling(sampler *Sampler) func(*core) {
+	return func(c *core) {
+		c.config.Sampler = sampler
+	}
+}
+
+// zapdriver core option to sync the wrapped core after every log, so no logs
+// are lost when the process is suspended or stopped right after logging, as
+// happens on Cloud Functions. Errors returned by the sync are ignored, since
+// syncing is not supported by all outputs, such as pipes
+func SyncAfterWrite(sync bool) func(*core) {
+	return func(c *core) {
+		c.config.SyncAfterWrite = sync
+	}
+}
+
+// zapdriver core option to keep the unset fields of the `HTTP()`,
+// `Operation()`, `SourceLocation()` and `ErrorReport()` fields, such as a zero
+// status or an empty latency, which are omitted by default
+func KeepZeroFields(keep bool) func(*core) {
+	return func(c *core) {
+		c.config.KeepZeroFields = keep
+	}
+}
+
+// zapdriver core option to trim a prefix, such as the directory the
+// application was built in, from the file paths of the source locations and
+// error reports added by the core
+func TrimSourcePrefix(prefix string) func(*core) {
+	return func(c *core) {
+		c.config.SourceFormat.prefix = prefix
+	}
+}
+
+// zapdriver core option to write the file paths of the main module relative to
+// the module root, such as "internal/orders/orders.go", based on the build info
+// of the binary. Files of other modules are not changed
+func TrimSourceModule(trim bool) func(*core) {
+	return func(c *core) {
+		c.config.SourceFormat.module, c.config.SourceFormat.mainPackage = "", ""
+		if trim {
+			c.config.SourceFormat.module, c.config.SourceFormat.mainPackage = mainModule()
+		}
+	}
+}
+
+// zapdriver core option to write function names without their package
+// directory, such as "orders.(*Service).Cancel" instead of
+// "github.com/acme/shop/internal/orders.(*Service).Cancel"
+func ShortFunctionNames(short bool) func(*core) {
+	return func(c *core) {
+		c.config.SourceFormat.shortFunctions = short
+	}
+}
+
+// zapdriver core option to add the source code revision to the error reports
+// of all logs, so Error Reporting can link to the reported source location. If
+// revision is empty, the VCS revision from the build info of the binary is used
+func SourceRevision(repository, revision string) func(*core) {
+	return func(c *core) {
+		if revision == "" {
+			revision = buildRevision()
+		}
+
+		c.config.SourceReference = nil
+		if revision != "" {
+			c.config.SourceReference = &sourceReference{Repository: repository, RevisionID: revision}
+		}
+	}
+}
+
+// zapdriver core option to skip the stack frames of wrapper packages, such as a
+// logging facade, when adding the source location and error report to logs. The
+// first caller outside of these packages is reported instead. Packages ending
+// in "/..." also match their subpackages
+func IgnoreCallerPackages(packages ...string) func(*core) {
+	return func(c *core) {
+		c.config.IgnoredPackages = packages
+	}
+}
+
 // WrapCore returns a `zap.Option` that wraps the default core with the
 // zapdriver one.
 func WrapCore(options ...func(*core)) zap.Option {
@@ -78,23 +295,51 @@ func WrapCore(options ...func(*core)) zap.Option {
 func (c *core) With(fields []zap.Field) zapcore.Core {
 	var lbls *labels
 	lbls, fields = c.extractLabels(fields)
+	if c.config.Redactor != nil {
+		fields = c.config.Redactor.fields(fields)
+	}
+
+	// Copy the labels, so they are not added to the parent core, or to other
+	// cores derived from it, such as request-scoped loggers.
+	permLabels := newLabels()
+
+	c.permLabels.mutex.RLock()
+	for k, v := range c.permLabels.store {
+		permLabels.store[k] = v
+	}
+	c.permLabels.mutex.RUnlock()
 
 	lbls.mutex.RLock()
-	c.permLabels.mutex.Lock()
 	for k, v := range lbls.store {
-		c.permLabels.store[k] = v
+		permLabels.store[k] = v
 	}
-	c.permLabels.mutex.Unlock()
 	lbls.mutex.RUnlock()
 
+	if c.config.KeepZeroFields {
+		fields = withZeroFields(fields)
+	}
+
 	return &core{
-		Core:       c.Core.With(fields),
-		permLabels: c.permLabels,
-		tempLabels: newLabels(),
-		config:     c.config,
+		Core:         c.Core.With(fields),
+		permLabels:   permLabels,
+		tempLabels:   newLabels(),
+		sampledTrace: c.sampledTrace || isTraceSampled(fields),
+		config:       c.config,
 	}
 }
 
+// Enabled reports whether the level is enabled by the embedded LevelEnabler,
+// or by any of the level overrides. The override of the entry's logger name is
+// only known to Check and Write, which both apply it, so wrapping cores that
+// gate on Enabled still drop the entries disabled by an override.
+func (c *core) Enabled(level zapcore.Level) bool {
+	if c.Core.Enabled(level) {
+		return true
+	}
+
+	return c.config.Levels != nil && c.config.Levels.enabled(level)
+}
+
 // Check determines whether the supplied Entry should be logged (using the
 // embedded LevelEnabler and possibly some extra logic). If the entry
 // should be logged, the Core adds itself to the CheckedEntry and returns
@@ -102,14 +347,33 @@ func (c *core) With(fields []zap.Field) zapcore.Core {
 //
 // Callers must use Check before calling Write.
 func (c *core) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
-	if c.Enabled(ent.Level) {
+	if c.entryEnabled(ent) {
 		return ce.AddCore(ent, c)
 	}
 
 	return ce
 }
 
+// entryEnabled reports whether the entry's level is enabled by the level
+// override of its logger name, or by the embedded LevelEnabler if there is no
+// override.
+func (c *core) entryEnabled(ent zapcore.Entry) bool {
+	if c.config.Levels != nil {
+		if level, ok := c.config.Levels.Level(ent.LoggerName); ok {
+			return level.Enabled(ent.Level)
+		}
+	}
+
+	return c.Core.Enabled(ent.Level)
+}
+
 func (c *core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	// Wrapping cores may only consult Enabled before calling Write, which
+	// does not know about the level override of the entry's logger name.
+	if c.config.Levels != nil && !c.entryEnabled(ent) {
+		return nil
+	}
+
 	var lbls *labels
 	lbls, fields = c.extractLabels(fields)
 
@@ -121,8 +385,37 @@ func (c *core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
 	c.tempLabels.mutex.Unlock()
 	lbls.mutex.RUnlock()
 
-	fields = append(fields, labelsField(c.allLabels()))
+	all := c.allLabels()
+	if c.config.Sampler != nil && !c.sampledTrace && !isTraceSampled(fields) {
+		keep, summary := c.config.Sampler.sample(ent, all.store)
+		if summary != nil {
+			c.writeSamplingSummary(ent, summary)
+		}
+
+		if !keep {
+			c.tempLabels.reset()
+			return nil
+		}
+	}
+
+	if c.config.Redactor != nil {
+		ent.Message = c.config.Redactor.message(ent.Message)
+		fields = c.config.Redactor.fields(fields)
+		c.config.Redactor.labels(all)
+	}
+
+	if c.config.MaxLabels > 0 || c.config.MaxLabelValueLength > 0 {
+		all.limit(c.config.MaxLabels, c.config.MaxLabelValueLength)
+	}
+
+	fields = append(fields, labelsField(all))
 	fields = c.withSourceLocation(ent, fields)
+	if c.config.InsertIDGenerator != nil {
+		fields = c.withInsertID(c.config.InsertIDGenerator, fields)
+	}
+	if c.config.ProjectID != "" {
+		fields = c.withProjectTrace(c.config.ProjectID, fields)
+	}
 	if c.config.ServiceName != "" {
 		fields = c.withServiceContext(c.config.ServiceName, fields)
 	}
@@ -135,13 +428,72 @@ func (c *core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
 		}
 	}
 
+	if c.config.ReportLimiter != nil {
+		fields = c.withErrorReportLimit(ent, fields)
+	}
+
+	if c.config.KeepZeroFields {
+		fields = withZeroFields(fields)
+	}
+
 	c.tempLabels.reset()
 
-	return c.Core.Write(ent, fields)
+	if err := c.Core.Write(ent, fields); err != nil {
+		return err
+	}
+
+	if c.config.SyncAfterWrite {
+		_ = c.Core.Sync()
+	}
+
+	return nil
 }
 
-// Sync flushes buffered logs (if any).
+// writeSamplingSummary writes an entry with the number of entries dropped by
+// each sampling rule during the previous tick.
+func (c *core) writeSamplingSummary(ent zapcore.Entry, summary samplingSummary) {
+	ent = zapcore.Entry{
+		Level:      zapcore.InfoLevel,
+		Time:       ent.Time,
+		LoggerName: ent.LoggerName,
+		Message:    "zapdriver: entries suppressed by sampling",
+	}
+
+	if c.Core.Enabled(ent.Level) {
+		_ = c.Core.Write(ent, []zapcore.Field{zap.Object("suppressed", summary)})
+	}
+}
+
+// writeSuppressedErrors writes an entry with the errors that were not reported
+// to Error Reporting during the previous window.
+func (c *core) writeSuppressedErrors(ent zapcore.Entry, summary reportSummary) {
+	ent = zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       ent.Time,
+		LoggerName: ent.LoggerName,
+		Message:    "zapdriver: error reports suppressed",
+	}
+
+	if c.Core.Enabled(ent.Level) {
+		_ = c.Core.Write(ent, []zapcore.Field{zap.Array("suppressed", summary)})
+	}
+}
+
+// Sync writes the pending sampling summary and suppressed error reports, and
+// flushes buffered logs (if any).
 func (c *core) Sync() error {
+	if c.config.Sampler != nil {
+		if summary := c.config.Sampler.flush(); len(summary) > 0 {
+			c.writeSamplingSummary(zapcore.Entry{Time: time.Now()}, summary)
+		}
+	}
+
+	if c.config.ReportLimiter != nil {
+		if summary := c.config.ReportLimiter.flush(); len(summary) > 0 {
+			c.writeSuppressedErrors(zapcore.Entry{Time: time.Now()}, summary)
+		}
+	}
+
 	return c.Core.Sync()
 }
 
@@ -149,6 +501,13 @@ func (c *core) allLabels() *labels {
 	lbls := newLabels()
 
 	lbls.mutex.Lock()
+	if r := c.config.Resource; r != nil {
+		lbls.store["resource.type"] = r.Type
+		for k, v := range r.Labels {
+			lbls.store["resource."+k] = v
+		}
+	}
+
 	c.permLabels.mutex.RLock()
 	for k, v := range c.permLabels.store {
 		lbls.store[k] = v
@@ -209,11 +568,60 @@ func (c *core) withSourceLocation(ent zapcore.Entry, fields []zapcore.Field) []z
 		}
 	}
 
-	if !ent.Caller.Defined {
+	frame, ok := c.caller(ent)
+	if !ok {
 		return fields
 	}
 
-	return append(fields, SourceLocation(ent.Caller.PC, ent.Caller.File, ent.Caller.Line, true))
+	source := &source{File: frame.File, Line: strconv.Itoa(frame.Line), Function: frame.Function}
+	c.config.SourceFormat.apply(&source.File, &source.Function)
+
+	return append(fields, zap.Object(sourceKey, source))
+}
+
+func (c *core) withInsertID(generator func() string, fields []zapcore.Field) []zapcore.Field {
+	// If the insert ID was manually set, don't overwrite it
+	for i := range fields {
+		if fields[i].Key == insertIDKey {
+			return fields
+		}
+	}
+
+	return append(fields, InsertID(generator()))
+}
+
+func (c *core) withErrorReportLimit(ent zapcore.Entry, fields []zapcore.Field) []zapcore.Field {
+	i := -1
+	for j := range fields {
+		if fields[j].Key == contextKey {
+			i = j
+			break
+		}
+	}
+
+	if i < 0 {
+		return fields
+	}
+
+	context, ok := fields[i].Interface.(*reportContext)
+	if !ok || context == nil {
+		return fields
+	}
+
+	n, allowed, summary := c.config.ReportLimiter.allow(ent.Message, context.ReportLocation, errorType(fields))
+	if summary != nil {
+		c.writeSuppressedErrors(ent, summary)
+	}
+
+	if allowed {
+		return fields
+	}
+
+	out := make([]zapcore.Field, 0, len(fields))
+	out = append(out, fields[:i]...)
+	out = append(out, fields[i+1:]...)
+
+	return append(out, zap.Uint64(suppressedCountKey, n-c.config.ReportLimiter.limit))
 }
 
 func (c *core) withServiceContext(name string, fields []zapcore.Field) []zapcore.Field {
@@ -224,7 +632,30 @@ func (c *core) withServiceContext(name string, fields []zapcore.Field) []zapcore
 		}
 	}
 
-	return append(fields, ServiceContext(name))
+	return append(fields, ServiceContextWithVersion(name, c.config.ServiceVersion))
+}
+
+func (c *core) withProjectTrace(projectID string, fields []zapcore.Field) []zapcore.Field {
+	for i := range fields {
+		if fields[i].Key != traceKey || fields[i].Type != zapcore.StringType {
+			continue
+		}
+
+		trace := fields[i].String
+		if strings.HasPrefix(trace, "projects//traces/") {
+			trace = strings.TrimPrefix(trace, "projects//traces/")
+		} else if strings.HasPrefix(trace, "projects/") {
+			return fields
+		}
+
+		out := make([]zapcore.Field, len(fields))
+		copy(out, fields)
+		out[i] = zap.String(traceKey, "projects/"+projectID+"/traces/"+trace)
+
+		return out
+	}
+
+	return fields
 }
 
 func (c *core) withErrorReport(ent zapcore.Entry, fields []zapcore.Field) []zapcore.Field {
@@ -235,9 +666,78 @@ func (c *core) withErrorReport(ent zapcore.Entry, fields []zapcore.Field) []zapc
 		}
 	}
 
-	if !ent.Caller.Defined {
+	frame, ok := c.caller(ent)
+	if !ok {
 		return fields
 	}
 
-	return append(fields, ErrorReport(ent.Caller.PC, ent.Caller.File, ent.Caller.Line, true))
+	context := &reportContext{
+		ReportLocation: reportLocation{File: frame.File, Line: strconv.Itoa(frame.Line), Function: frame.Function},
+	}
+	c.config.SourceFormat.apply(&context.ReportLocation.File, &context.ReportLocation.Function)
+	if c.config.SourceReference != nil {
+		context.SourceReferences = sourceReferences{*c.config.SourceReference}
+	}
+
+	return append(fields, zap.Object(contextKey, context))
+}
+
+// caller returns the caller of the entry, skipping the frames of the ignored
+// packages.
+func (c *core) caller(ent zapcore.Entry) (runtime.Frame, bool) {
+	if !ent.Caller.Defined {
+		return runtime.Frame{}, false
+	}
+
+	frame := runtime.Frame{File: ent.Caller.File, Line: ent.Caller.Line}
+	if fn := runtime.FuncForPC(ent.Caller.PC); fn != nil {
+		frame.Function = fn.Name()
+	}
+
+	if !c.ignored(frame.Function) {
+		return frame, true
+	}
+
+	// Find the caller of the entry in the current stack, and ascend from there
+	// until the first frame outside of the ignored packages.
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+
+	found := false
+	for {
+		f, more := frames.Next()
+		if found && !c.ignored(f.Function) {
+			return f, true
+		}
+
+		if f.File == ent.Caller.File && f.Line == ent.Caller.Line {
+			found = true
+		}
+
+		if !more {
+			return frame, true
+		}
+	}
+}
+
+// ignored returns whether function belongs to one of the ignored packages.
+func (c *core) ignored(function string) bool {
+	if len(c.config.IgnoredPackages) == 0 {
+		return false
+	}
+
+	pkg := functionPackage(function)
+	for _, ignored := range c.config.IgnoredPackages {
+		if pkg == ignored {
+			return true
+		}
+
+		if prefix := strings.TrimSuffix(ignored, "/..."); prefix != ignored {
+			if pkg == prefix || strings.HasPrefix(pkg, prefix+"/") {
+				return true
+			}
+		}
+	}
+
+	return false
 }
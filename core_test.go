@@ -1,8 +1,10 @@
 package zapdriver
 
 import (
+	"bytes"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -136,6 +138,29 @@ func TestWithErrorReport_OnlyWhenDefined(t *testing.T) {
 	assert.Equal(t, want, (&core{}).withErrorReport(ent, fields))
 }
 
+func TestWithInsertID(t *testing.T) {
+	fields := []zap.Field{zap.String("hello", "world")}
+	generator := func() string { return "abc" }
+
+	want := []zap.Field{
+		zap.String("hello", "world"),
+		zap.String(insertIDKey, "abc"),
+	}
+
+	assert.Equal(t, want, (&core{}).withInsertID(generator, fields))
+}
+
+func TestWithInsertID_DoesNotOverwrite(t *testing.T) {
+	fields := []zap.Field{InsertID("world")}
+	generator := func() string { return "abc" }
+
+	want := []zap.Field{
+		zap.String(insertIDKey, "world"),
+	}
+
+	assert.Equal(t, want, (&core{}).withInsertID(generator, fields))
+}
+
 func TestWithServiceContext(t *testing.T) {
 	fields := []zap.Field{zap.String("hello", "world")}
 
@@ -359,6 +384,18 @@ func TestWriteReportAllErrors_InfoLog(t *testing.T) {
 	assert.NotContains(t, logs.All()[0].ContextMap(), serviceContextKey)
 }
 
+func TestWriteInsertIDs(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(GenerateInsertIDs(nil)))
+
+	logger.Info("one")
+	logger.Info("two", InsertID("manual"))
+
+	first := logs.All()[0].ContextMap()[insertIDKey].(string)
+	assert.NotEmpty(t, first)
+	assert.Equal(t, "manual", logs.All()[1].ContextMap()[insertIDKey])
+}
+
 func TestAllLabels(t *testing.T) {
 	perm := newLabels()
 	perm.store = map[string]string{"one": "1", "two": "2", "three": "3"}
@@ -381,3 +418,156 @@ func TestAllLabels(t *testing.T) {
 	assert.Equal(t, out.store["three"], "THREE")
 	out.mutex.RUnlock()
 }
+
+func TestWithProjectTrace(t *testing.T) {
+	tests := map[string]string{
+		"abc":                       "projects/project/traces/abc",
+		"projects//traces/abc":      "projects/project/traces/abc",
+		"projects/other/traces/abc": "projects/other/traces/abc",
+	}
+
+	for trace, want := range tests {
+		fields := []zap.Field{zap.String("foo", "bar"), zap.String(traceKey, trace)}
+		got := (&core{}).withProjectTrace("project", fields)
+
+		assert.Equal(t, []zap.Field{zap.String("foo", "bar"), zap.String(traceKey, want)}, got)
+		assert.Equal(t, trace, fields[1].String)
+	}
+}
+
+func TestWriteServiceVersionAndProjectID(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(ServiceName("service"), ServiceVersion("v1"), ProjectID("project")))
+
+	logger.Info("hello", TraceContext("abc", "def", true, "")...)
+
+	context := logs.All()[0].ContextMap()
+	assert.Equal(t, map[string]interface{}{"service": "service", "version": "v1"}, context[serviceContextKey])
+	assert.Equal(t, "projects/project/traces/abc", context[traceKey])
+}
+
+func TestWriteLabelLimits(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(LabelLimits(2, 3)))
+
+	logger.Info("hello", Label("c", "three"), Label("a", "one"), Label("b", "twö"))
+
+	assert.Equal(t, map[string]interface{}{"a": "one", "b": "tw"}, logs.All()[0].ContextMap()[labelsKey])
+}
+
+type syncCounter struct {
+	zapcore.Core
+	syncs int
+}
+
+func (s *syncCounter) Sync() error {
+	s.syncs++
+	return nil
+}
+
+func TestWriteSyncAfterWrite(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	inner := &syncCounter{Core: debugcore}
+	logger := zap.New(inner, WrapCore(SyncAfterWrite(true)))
+
+	logger.Info("one")
+	logger.Info("two")
+
+	assert.Equal(t, 2, logs.Len())
+	assert.Equal(t, 2, inner.syncs)
+}
+
+func TestWith_DoesNotShareLabels(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore())
+
+	parent := logger.With(Label("parent", "yes"))
+	parent.With(Label("one", "1")).Info("one")
+	parent.With(Label("two", "2")).Info("two")
+	parent.Info("parent")
+	logger.Info("root")
+
+	labels := func(i int) interface{} { return logs.All()[i].ContextMap()[labelsKey] }
+
+	assert.Equal(t, map[string]interface{}{"parent": "yes", "one": "1"}, labels(0))
+	assert.Equal(t, map[string]interface{}{"parent": "yes", "two": "2"}, labels(1))
+	assert.Equal(t, map[string]interface{}{"parent": "yes"}, labels(2))
+	assert.Equal(t, map[string]interface{}{}, labels(3))
+}
+
+func TestWrite_KeepZeroFields(t *testing.T) {
+	buf := &bytes.Buffer{}
+	encoder := zapcore.NewJSONEncoder(zapcore.EncoderConfig{})
+	core := zapcore.NewCore(encoder, zapcore.AddSync(buf), zapcore.DebugLevel)
+
+	logger := zap.New(core, WrapCore(KeepZeroFields(true)))
+	logger.With(OperationCont("id", "producer")).Info("hello", HTTP(&HTTPPayload{Status: 200}))
+
+	assert.Equal(t, `{"logging.googleapis.com/operation":{"id":"id","producer":"producer","first":false,"last":false},`+
+		`"httpRequest":{"requestMethod":"","requestUrl":"","requestSize":"","status":200,"responseSize":"",`+
+		`"userAgent":"","remoteIp":"","serverIp":"","referer":"","latency":"","cacheLookup":false,"cacheHit":false,`+
+		`"cacheValidatedWithOriginServer":false,"cacheFillBytes":"","protocol":""},"logging.googleapis.com/labels":{}}`+"\n", buf.String())
+}
+
+// encodeJSON returns the JSON encoding of an entry with obj as its only field.
+func encodeJSON(t *testing.T, key string, obj zapcore.ObjectMarshaler) string {
+	buf, err := zapcore.NewJSONEncoder(zapcore.EncoderConfig{}).EncodeEntry(zapcore.Entry{}, []zap.Field{zap.Object(key, obj)})
+	require.NoError(t, err)
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func TestWriteSourceFormat(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	_, file, _, _ := runtime.Caller(0)
+	prefix := file[:strings.LastIndex(file, "/")+1]
+
+	logger := zap.New(debugcore, zap.AddCaller(), WrapCore(
+		ReportAllErrors(true),
+		TrimSourcePrefix(prefix),
+		ShortFunctionNames(true),
+		SourceRevision("https://github.com/blendle/zapdriver", "abc123"),
+	))
+	logger.Error("oops")
+
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, "core_test.go", fields[sourceKey].(map[string]interface{})["file"])
+	assert.Equal(t, "zapdriver.TestWriteSourceFormat", fields[sourceKey].(map[string]interface{})["function"])
+
+	context := fields[contextKey].(map[string]interface{})
+	assert.Equal(t, "core_test.go", context["reportLocation"].(map[string]interface{})["filePath"])
+	assert.Equal(t, "zapdriver.TestWriteSourceFormat", context["reportLocation"].(map[string]interface{})["functionName"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"repository": "https://github.com/blendle/zapdriver", "revisionId": "abc123"},
+	}, context["sourceReferences"])
+}
+
+func TestWriteIgnoreCallerPackages(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+
+	// All frames of this package are ignored, so the first frame outside of
+	// it is the test runner.
+	logger := zap.New(debugcore, zap.AddCaller(), WrapCore(
+		ReportAllErrors(true),
+		IgnoreCallerPackages("github.com/blendle/zapdriver"),
+	))
+	logger.Error("oops")
+
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, "testing.tRunner", fields[sourceKey].(map[string]interface{})["function"])
+	assert.Contains(t, fields[sourceKey].(map[string]interface{})["file"], "testing/testing.go")
+
+	context := fields[contextKey].(map[string]interface{})
+	assert.Equal(t, "testing.tRunner", context["reportLocation"].(map[string]interface{})["functionName"])
+}
+
+func TestCore_Ignored(t *testing.T) {
+	c := &core{config: driverConfig{IgnoredPackages: []string{"github.com/acme/logging", "github.com/acme/log/..."}}}
+
+	assert.True(t, c.ignored("github.com/acme/logging.(*Logger).Info"))
+	assert.False(t, c.ignored("github.com/acme/logging/json.Encode"))
+	assert.True(t, c.ignored("github.com/acme/log.Info"))
+	assert.True(t, c.ignored("github.com/acme/log/json.Encode"))
+	assert.False(t, c.ignored("github.com/acme/logger.Info"))
+	assert.False(t, c.ignored("main.main"))
+}
@@ -30,6 +30,19 @@ var logLevelSeverity = map[zapcore.Level]string{
 	zapcore.FatalLevel:  "EMERGENCY",
 }
 
+// severityRanks maps the Stackdriver severity names to their numeric values.
+var severityRanks = map[string]int{
+	"DEFAULT":   0,
+	"DEBUG":     100,
+	"INFO":      200,
+	"NOTICE":    300,
+	"WARNING":   400,
+	"ERROR":     500,
+	"CRITICAL":  600,
+	"ALERT":     700,
+	"EMERGENCY": 800,
+}
+
 // encoderConfig is the default encoder configuration, slightly tweaked to use
 // the correct fields for Stackdriver to parse them.
 var encoderConfig = zapcore.EncoderConfig{
@@ -57,3 +70,14 @@ func EncodeLevel(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
 func RFC3339NanoTimeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
 	enc.AppendString(t.Format(time.RFC3339Nano))
 }
+
+// SeverityNumber returns the numeric value of a Stackdriver severity name, such
+// as 500 for "ERROR". The second return value is false if the name is not a
+// known severity.
+//
+// see: https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#LogSeverity
+func SeverityNumber(severity string) (int, bool) {
+	n, ok := severityRanks[severity]
+
+	return n, ok
+}
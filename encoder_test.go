@@ -48,3 +48,18 @@ func TestRFC3339NanoTimeEncoder(t *testing.T) {
 	require.Len(t, enc.elems, 1)
 	assert.Equal(t, ts.Format(time.RFC3339Nano), enc.elems[0].(string))
 }
+
+func TestSeverityNumber(t *testing.T) {
+	t.Parallel()
+
+	n, ok := zapdriver.SeverityNumber("ERROR")
+	assert.True(t, ok)
+	assert.Equal(t, 500, n)
+
+	n, ok = zapdriver.SeverityNumber("DEFAULT")
+	assert.True(t, ok)
+	assert.Equal(t, 0, n)
+
+	_, ok = zapdriver.SeverityNumber("error")
+	assert.False(t, ok)
+}
@@ -0,0 +1,225 @@
+package zapdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// eventHandler logs the events delivered to the wrapped handler.
+type eventHandler struct {
+	logger *zap.Logger
+	next   http.Handler
+
+	// ProjectID is used to build the trace resource names.
+	ProjectID string
+
+	// TaskFields adds the `TaskFields()` of the requests to the logger.
+	TaskFields bool
+}
+
+// EventProjectID sets the project ID used to build the trace resource names.
+// If it is not set, use the `ProjectID()` core option to complete them.
+func EventProjectID(projectID string) func(*eventHandler) {
+	return func(h *eventHandler) {
+		h.ProjectID = projectID
+	}
+}
+
+// EventTaskFields adds the labels and operation of Cloud Tasks and Cloud
+// Scheduler requests to the logger, see `TaskFields()`.
+func EventTaskFields(enabled bool) func(*eventHandler) {
+	return func(h *eventHandler) {
+		h.TaskFields = enabled
+	}
+}
+
+// pushEnvelope is the body of a Pub/Sub push request.
+//
+// see: https://cloud.google.com/pubsub/docs/push#receive_push
+type pushEnvelope struct {
+	Message struct {
+		ID          string            `json:"messageId"`
+		Attributes  map[string]string `json:"attributes"`
+		OrderingKey string            `json:"orderingKey"`
+	} `json:"message"`
+	Subscription    string `json:"subscription"`
+	DeliveryAttempt int    `json:"deliveryAttempt"`
+}
+
+// structuredEvent is the body of a CloudEvent in the structured content mode.
+//
+// see: https://github.com/cloudevents/spec/blob/v1.0/http-protocol-binding.md#32-structured-content-mode
+type structuredEvent struct {
+	ID          string          `json:"id"`
+	Source      string          `json:"source"`
+	Type        string          `json:"type"`
+	Subject     string          `json:"subject"`
+	Traceparent string          `json:"traceparent"`
+	Data        json.RawMessage `json:"data"`
+}
+
+// EventHandler wraps a handler that receives Pub/Sub push messages or
+// CloudEvents, such as those delivered by Eventarc.
+//
+// It adds the message ID, subscription, event ID, type and source as labels to
+// a request-scoped logger, together with the trace context of the message
+// attributes, the `traceparent` extension or the request headers. The logger is
+// passed to the wrapped handler in the request context, see
+// `LoggerFromContext()`.
+//
+// After the wrapped handler returns, the outcome is logged with the latency. A
+// 2xx status acknowledges the message, any other status makes the sender
+// deliver it again.
+func EventHandler(logger *zap.Logger, next http.Handler, options ...func(*eventHandler)) http.Handler {
+	h := &eventHandler{logger: logger, next: next}
+	for _, option := range options {
+		option(h)
+	}
+
+	return h
+}
+
+func (h *eventHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	var body []byte
+	if r.Body != nil {
+		body, _ = ioutil.ReadAll(r.Body) // nolint: gas
+		r.Body.Close()                   // nolint: errcheck
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	fields, trace := h.eventFields(r, body)
+	if trace == nil {
+		trace = TraceContextFromRequest(r, h.ProjectID)
+	}
+
+	fields = append(fields, trace...)
+	if h.TaskFields {
+		fields = append(fields, TaskFields(r)...)
+	}
+
+	logger := h.logger.With(fields...)
+	logger.Debug("event received")
+
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	h.next.ServeHTTP(rec, r.WithContext(ContextWithLogger(r.Context(), logger)))
+
+	payload := &HTTPPayload{
+		RequestMethod: r.Method,
+		RequestURL:    r.URL.String(),
+		RequestSize:   strconv.Itoa(len(body)),
+		Status:        rec.status,
+		ResponseSize:  strconv.Itoa(rec.size),
+		UserAgent:     r.UserAgent(),
+		RemoteIP:      r.RemoteAddr,
+		Latency:       formatLatency(time.Since(start)),
+		Protocol:      r.Proto,
+	}
+
+	if rec.status >= 200 && rec.status < 300 {
+		logger.Info("event acknowledged", zap.String("outcome", "ack"), HTTP(payload))
+	} else {
+		logger.Warn("event not acknowledged", zap.String("outcome", "nack"), HTTP(payload))
+	}
+}
+
+// eventFields returns the labels of the event, and its trace context if the
+// event carries one.
+func (h *eventHandler) eventFields(r *http.Request, body []byte) ([]zap.Field, []zap.Field) {
+	var fields, trace []zap.Field
+
+	data := body
+	if id := r.Header.Get("Ce-Id"); id != "" {
+		// Binary content mode, with the attributes in the headers.
+		fields = appendLabels(fields, "cloudevents.",
+			"id", id,
+			"source", r.Header.Get("Ce-Source"),
+			"type", r.Header.Get("Ce-Type"),
+			"subject", r.Header.Get("Ce-Subject"),
+		)
+		trace = traceparentContext(r.Header.Get("Ce-Traceparent"), h.ProjectID)
+
+	} else if t, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); t == "application/cloudevents+json" {
+		var event structuredEvent
+		if err := json.Unmarshal(body, &event); err == nil {
+			fields = appendLabels(fields, "cloudevents.",
+				"id", event.ID,
+				"source", event.Source,
+				"type", event.Type,
+				"subject", event.Subject,
+			)
+			trace = traceparentContext(event.Traceparent, h.ProjectID)
+			data = event.Data
+		}
+	}
+
+	var envelope pushEnvelope
+	if err := json.Unmarshal(data, &envelope); err == nil && envelope.Message.ID != "" {
+		attempt := ""
+		if envelope.DeliveryAttempt > 0 {
+			attempt = strconv.Itoa(envelope.DeliveryAttempt)
+		}
+
+		fields = appendLabels(fields, "pubsub.",
+			"message_id", envelope.Message.ID,
+			"subscription", envelope.Subscription,
+			"ordering_key", envelope.Message.OrderingKey,
+			"delivery_attempt", attempt,
+		)
+
+		for _, key := range []string{"googclient_traceparent", "traceparent"} {
+			if t := traceparentContext(envelope.Message.Attributes[key], h.ProjectID); t != nil {
+				trace = t
+				break
+			}
+		}
+	}
+
+	return fields, trace
+}
+
+// appendLabels appends the non-empty key/value pairs as labels, with their keys
+// prefixed.
+func appendLabels(fields []zap.Field, prefix string, pairs ...string) []zap.Field {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			fields = append(fields, Label(prefix+pairs[i], pairs[i+1]))
+		}
+	}
+
+	return fields
+}
+
+// statusRecorder records the status and size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+
+	status      int
+	size        int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status, r.wroteHeader = status, true
+	}
+
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+
+	n, err := r.ResponseWriter.Write(b)
+	r.size += n
+
+	return n, err
+}
@@ -0,0 +1,153 @@
+package zapdriver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const pushBody = `{
+	"message": {
+		"attributes": {"googclient_traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		"data": "aGVsbG8=",
+		"messageId": "136969346945",
+		"publishTime": "2019-06-01T10:00:00Z"
+	},
+	"subscription": "projects/my-project/subscriptions/my-subscription",
+	"deliveryAttempt": 2
+}`
+
+func serveEvent(t *testing.T, r *http.Request, status int) (*observer.ObservedLogs, string) {
+	t.Helper()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore())
+
+	var body string
+	handler := EventHandler(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		body = string(b)
+
+		LoggerFromContext(r.Context()).Info("handling")
+		w.WriteHeader(status)
+	}), EventProjectID("my-project"))
+
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	return logs, body
+}
+
+func TestEventHandler_PubSubPush(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/push", strings.NewReader(pushBody))
+	logs, body := serveEvent(t, r, http.StatusNoContent)
+
+	assert.Equal(t, pushBody, body, "the body is passed to the handler")
+	require.Equal(t, 3, logs.Len())
+	assert.Equal(t, []string{"event received", "handling", "event acknowledged"}, messages(logs))
+
+	for _, entry := range logs.All() {
+		context := entry.ContextMap()
+
+		assert.Equal(t, map[string]interface{}{
+			"pubsub.message_id":       "136969346945",
+			"pubsub.subscription":     "projects/my-project/subscriptions/my-subscription",
+			"pubsub.delivery_attempt": "2",
+		}, context[labelsKey])
+		assert.Equal(t, "projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736", context[traceKey])
+		assert.Equal(t, true, context[traceSampledKey])
+	}
+
+	outcome := logs.All()[2].ContextMap()
+	assert.Equal(t, "ack", outcome["outcome"])
+	assert.Equal(t, 204, outcome["httpRequest"].(map[string]interface{})["status"])
+	assert.Regexp(t, `^\d+(\.\d{1,9})?s$`, outcome["httpRequest"].(map[string]interface{})["latency"])
+}
+
+func TestEventHandler_CloudEventBinary(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"bucket": "my-bucket"}`))
+	r.Header.Set("Ce-Id", "1234")
+	r.Header.Set("Ce-Source", "//storage.googleapis.com/projects/_/buckets/my-bucket")
+	r.Header.Set("Ce-Type", "google.cloud.storage.object.v1.finalized")
+	r.Header.Set("Ce-Specversion", "1.0")
+	r.Header.Set("X-Cloud-Trace-Context", "105445aa7843bc8bf206b12000100000/1;o=1")
+
+	logs, _ := serveEvent(t, r, http.StatusInternalServerError)
+	require.Equal(t, 3, logs.Len())
+
+	outcome := logs.All()[2]
+	assert.Equal(t, "event not acknowledged", outcome.Message)
+	assert.Equal(t, zapcore.WarnLevel, outcome.Level)
+	assert.Equal(t, "nack", outcome.ContextMap()["outcome"])
+	assert.Equal(t, map[string]interface{}{
+		"cloudevents.id":     "1234",
+		"cloudevents.source": "//storage.googleapis.com/projects/_/buckets/my-bucket",
+		"cloudevents.type":   "google.cloud.storage.object.v1.finalized",
+	}, outcome.ContextMap()[labelsKey])
+	assert.Equal(t, "projects/my-project/traces/105445aa7843bc8bf206b12000100000", outcome.ContextMap()[traceKey])
+}
+
+func TestEventHandler_CloudEventStructuredPubSub(t *testing.T) {
+	body := `{
+		"specversion": "1.0",
+		"id": "5678",
+		"source": "//pubsub.googleapis.com/projects/my-project/topics/my-topic",
+		"type": "google.cloud.pubsub.topic.v1.messagePublished",
+		"data": ` + pushBody + `
+	}`
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/cloudevents+json; charset=utf-8")
+
+	logs, _ := serveEvent(t, r, http.StatusOK)
+	require.Equal(t, 3, logs.Len())
+
+	labels := logs.All()[0].ContextMap()[labelsKey].(map[string]interface{})
+	assert.Equal(t, "5678", labels["cloudevents.id"])
+	assert.Equal(t, "google.cloud.pubsub.topic.v1.messagePublished", labels["cloudevents.type"])
+	assert.Equal(t, "136969346945", labels["pubsub.message_id"])
+}
+
+func TestEventHandler_Unknown(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`not json`))
+	logs, body := serveEvent(t, r, http.StatusOK)
+
+	assert.Equal(t, "not json", body)
+	require.Equal(t, 3, logs.Len())
+	assert.Equal(t, map[string]interface{}{}, logs.All()[2].ContextMap()[labelsKey])
+	assert.NotContains(t, logs.All()[2].ContextMap(), traceKey)
+}
+
+func TestFormatLatency(t *testing.T) {
+	tests := map[time.Duration]string{
+		0:                       "0s",
+		3 * time.Second:         "3s",
+		3500 * time.Millisecond: "3.5s",
+		250 * time.Millisecond:  "0.25s",
+		time.Nanosecond:         "0.000000001s",
+		90*time.Second + 123456789*time.Nanosecond: "90.123456789s",
+		-1500 * time.Millisecond:                   "-1.5s",
+	}
+
+	for d, want := range tests {
+		assert.Equal(t, want, formatLatency(d), d.String())
+	}
+}
+
+func messages(logs *observer.ObservedLogs) []string {
+	var out []string
+	for _, entry := range logs.All() {
+		out = append(out, entry.Message)
+	}
+
+	return out
+}
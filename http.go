@@ -12,9 +12,12 @@ package zapdriver
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -126,35 +129,104 @@ func NewHTTP(req *http.Request, res *http.Response) *HTTPPayload {
 	buf := &bytes.Buffer{}
 	if req.Body != nil {
 		n, _ := io.Copy(buf, req.Body) // nolint: gas
-		sdreq.RequestSize = strconv.FormatInt(n, 10)
+		sdreq.SetRequestSize(n)
 	}
 
 	if res.Body != nil {
 		buf.Reset()
 		n, _ := io.Copy(buf, res.Body) // nolint: gas
-		sdreq.ResponseSize = strconv.FormatInt(n, 10)
+		sdreq.SetResponseSize(n)
 	}
 
 	return sdreq
 }
 
-// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+// SetLatency sets the request processing latency, formatted in seconds with up
+// to nine fractional digits, as expected by Stackdriver.
+func (req *HTTPPayload) SetLatency(d time.Duration) {
+	req.Latency = formatLatency(d)
+}
+
+// SetRequestSize sets the size of the HTTP request message in bytes.
+func (req *HTTPPayload) SetRequestSize(n int64) {
+	req.RequestSize = strconv.FormatInt(n, 10)
+}
+
+// SetResponseSize sets the size of the HTTP response message in bytes.
+func (req *HTTPPayload) SetResponseSize(n int64) {
+	req.ResponseSize = strconv.FormatInt(n, 10)
+}
+
+// SetCacheFillBytes sets the number of HTTP response bytes inserted into cache.
+func (req *HTTPPayload) SetCacheFillBytes(n int64) {
+	req.CacheFillBytes = strconv.FormatInt(n, 10)
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaller interface. Unset fields
+// are omitted, unless the `KeepZeroFields` core option is used.
+//
+// Malformed latencies and sizes are dropped instead of being rejected by
+// Stackdriver. Latencies in any format understood by time.ParseDuration, such
+// as "350ms", are converted to the expected format.
 func (req HTTPPayload) MarshalLogObject(enc zapcore.ObjectEncoder) error {
-	enc.AddString("requestMethod", req.RequestMethod)
-	enc.AddString("requestUrl", req.RequestURL)
-	enc.AddString("requestSize", req.RequestSize)
-	enc.AddInt("status", req.Status)
-	enc.AddString("responseSize", req.ResponseSize)
-	enc.AddString("userAgent", req.UserAgent)
-	enc.AddString("remoteIp", req.RemoteIP)
-	enc.AddString("serverIp", req.ServerIP)
-	enc.AddString("referer", req.Referer)
-	enc.AddString("latency", req.Latency)
-	enc.AddBool("cacheLookup", req.CacheLookup)
-	enc.AddBool("cacheHit", req.CacheHit)
-	enc.AddBool("cacheValidatedWithOriginServer", req.CacheValidatedWithOriginServer)
-	enc.AddString("cacheFillBytes", req.CacheFillBytes)
-	enc.AddString("protocol", req.Protocol)
+	return req.marshalLogObject(enc, true)
+}
+
+func (req HTTPPayload) marshalLogObject(enc zapcore.ObjectEncoder, omitZero bool) error {
+	e := zeroFieldsEncoder{enc, omitZero}
+
+	e.AddString("requestMethod", req.RequestMethod)
+	e.AddString("requestUrl", req.RequestURL)
+	e.AddString("requestSize", validSize(req.RequestSize))
+	e.AddInt("status", req.Status)
+	e.AddString("responseSize", validSize(req.ResponseSize))
+	e.AddString("userAgent", req.UserAgent)
+	e.AddString("remoteIp", req.RemoteIP)
+	e.AddString("serverIp", req.ServerIP)
+	e.AddString("referer", req.Referer)
+	e.AddString("latency", validLatency(req.Latency))
+	e.AddBool("cacheLookup", req.CacheLookup)
+	e.AddBool("cacheHit", req.CacheHit)
+	e.AddBool("cacheValidatedWithOriginServer", req.CacheValidatedWithOriginServer)
+	e.AddString("cacheFillBytes", validSize(req.CacheFillBytes))
+	e.AddString("protocol", req.Protocol)
 
 	return nil
 }
+
+// validSize returns s if it is a valid, non-negative size in bytes, or an empty
+// string otherwise.
+func validSize(s string) string {
+	if n, err := strconv.ParseInt(s, 10, 64); err != nil || n < 0 {
+		return ""
+	}
+
+	return s
+}
+
+// validLatency returns s formatted as expected by Stackdriver, or an empty
+// string if it is not a valid duration.
+func validLatency(s string) string {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return ""
+	}
+
+	return formatLatency(d)
+}
+
+// formatLatency formats a duration as expected by Stackdriver: in seconds, with
+// up to nine fractional digits, terminated by 's'. Example: "3.5s".
+func formatLatency(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+
+	seconds, nanos := d/time.Second, d%time.Second
+	if nanos == 0 {
+		return fmt.Sprintf("%s%ds", sign, seconds)
+	}
+
+	return fmt.Sprintf("%s%d.%ss", sign, seconds, strings.TrimRight(fmt.Sprintf("%09d", nanos), "0"))
+}
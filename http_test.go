@@ -7,10 +7,13 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/blendle/zapdriver"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 func TestHTTP(t *testing.T) {
@@ -121,3 +124,76 @@ func TestNewHTTP(t *testing.T) {
 		})
 	}
 }
+
+func TestHTTPPayload_Setters(t *testing.T) {
+	t.Parallel()
+
+	req := &zapdriver.HTTPPayload{}
+	req.SetLatency(1500 * time.Millisecond)
+	req.SetRequestSize(12)
+	req.SetResponseSize(1 << 20)
+	req.SetCacheFillBytes(0)
+
+	assert.Equal(t, &zapdriver.HTTPPayload{
+		Latency:        "1.5s",
+		RequestSize:    "12",
+		ResponseSize:   "1048576",
+		CacheFillBytes: "0",
+	}, req)
+}
+
+func TestHTTPPayload_MarshalLogObject_Validation(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		req           zapdriver.HTTPPayload
+		latency, size interface{}
+	}{
+		"valid":           {zapdriver.HTTPPayload{Latency: "3.5s", RequestSize: "12"}, "3.5s", "12"},
+		"empty":           {zapdriver.HTTPPayload{}, nil, nil},
+		"other unit":      {zapdriver.HTTPPayload{Latency: "350ms"}, "0.35s", nil},
+		"no unit":         {zapdriver.HTTPPayload{Latency: "0.35"}, nil, nil},
+		"too precise":     {zapdriver.HTTPPayload{Latency: "0.0000000001s"}, "0s", nil},
+		"negative size":   {zapdriver.HTTPPayload{RequestSize: "-1"}, nil, nil},
+		"non-number size": {zapdriver.HTTPPayload{RequestSize: "12kB"}, nil, nil},
+	}
+
+	for name, tt := range tests {
+		enc := zapcore.NewMapObjectEncoder()
+		require.NoError(t, tt.req.MarshalLogObject(enc), name)
+
+		assert.Equal(t, tt.latency, enc.Fields["latency"], name)
+		assert.Equal(t, tt.size, enc.Fields["requestSize"], name)
+	}
+}
+
+func TestHTTPPayload_MarshalLogObject_JSON(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		req  *zapdriver.HTTPPayload
+		want string
+	}{
+		"empty": {&zapdriver.HTTPPayload{}, `{"httpRequest":{}}`},
+		"request": {
+			&zapdriver.HTTPPayload{RequestMethod: "GET", RequestURL: "/", Status: 200, Latency: "0.5s"},
+			`{"httpRequest":{"requestMethod":"GET","requestUrl":"/","status":200,"latency":"0.5s"}}`,
+		},
+		"cache": {
+			&zapdriver.HTTPPayload{CacheLookup: true, CacheFillBytes: "0"},
+			`{"httpRequest":{"cacheLookup":true,"cacheFillBytes":"0"}}`,
+		},
+	}
+
+	for name, tt := range tests {
+		assert.JSONEq(t, tt.want, encodeJSON(t, zapdriver.HTTP(tt.req)), name)
+	}
+}
+
+// encodeJSON returns the JSON encoding of the field.
+func encodeJSON(t *testing.T, field zap.Field) string {
+	buf, err := zapcore.NewJSONEncoder(zapcore.EncoderConfig{}).EncodeEntry(zapcore.Entry{}, []zap.Field{field})
+	require.NoError(t, err)
+
+	return buf.String()
+}
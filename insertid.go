@@ -0,0 +1,47 @@
+package zapdriver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const insertIDKey = "logging.googleapis.com/insertId"
+
+// InsertID adds the correct Stackdriver "insertId" field.
+//
+// Cloud Logging uses the insert ID to order entries that have the same
+// timestamp, and to remove duplicate entries with the same timestamp and insert
+// ID.
+//
+// see: https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry
+func InsertID(id string) zap.Field {
+	return zap.String(insertIDKey, id)
+}
+
+// NewInsertIDGenerator returns a function that generates unique insert IDs. The
+// IDs consist of a random prefix, unique to the generator, and a monotonic
+// counter, so that IDs sort in the order in which they were generated.
+func NewInsertIDGenerator() func() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		b = strconv.AppendInt(b[:0], time.Now().UnixNano(), 36)
+	}
+
+	prefix := hex.EncodeToString(b) + "-"
+	var counter uint64
+
+	return func() string {
+		n := strconv.FormatUint(atomic.AddUint64(&counter, 1), 10)
+
+		// Pad the counter, so IDs sort lexicographically in the order in which
+		// they were generated.
+		return prefix + zeros[:20-len(n)] + n
+	}
+}
+
+const zeros = "00000000000000000000"
@@ -0,0 +1,32 @@
+package zapdriver
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestInsertID(t *testing.T) {
+	t.Parallel()
+
+	field := InsertID("abc")
+
+	assert.Equal(t, zap.String(insertIDKey, "abc"), field)
+}
+
+func TestNewInsertIDGenerator(t *testing.T) {
+	t.Parallel()
+
+	generate := NewInsertIDGenerator()
+
+	ids := make([]string, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		ids = append(ids, generate())
+	}
+
+	assert.True(t, sort.StringsAreSorted(ids))
+	assert.NotEqual(t, ids[0], ids[1])
+	assert.NotEqual(t, ids[0], NewInsertIDGenerator()())
+}
@@ -1,8 +1,10 @@
 package zapdriver
 
 import (
+	"sort"
 	"strings"
 	"sync"
+	"unicode/utf8"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -66,6 +68,39 @@ func (l *labels) reset() {
 	l.mutex.Unlock()
 }
 
+// limit drops the labels beyond maxLabels in alphabetical order, and truncates
+// values longer than maxValueLength bytes. A limit of zero disables it.
+func (l *labels) limit(maxLabels, maxValueLength int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if maxLabels > 0 && len(l.store) > maxLabels {
+		keys := make([]string, 0, len(l.store))
+		for k := range l.store {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys[maxLabels:] {
+			delete(l.store, k)
+		}
+	}
+
+	if maxValueLength > 0 {
+		for k, v := range l.store {
+			if len(v) <= maxValueLength {
+				continue
+			}
+
+			v = v[:maxValueLength]
+			for len(v) > 0 && !utf8.ValidString(v) {
+				v = v[:len(v)-1]
+			}
+			l.store[k] = v
+		}
+	}
+}
+
 func (l labels) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 	l.mutex.RLock()
 	for k, v := range l.store {
@@ -0,0 +1,236 @@
+package zapdriver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// LevelRegistry holds log level overrides per logger name, that can be changed
+// at runtime. Use the `LevelOverrides()` core option to apply them.
+//
+// Overrides are set for a logger name pattern. A pattern matches the logger with
+// that name, a pattern ending in `.*` also matches all its descendants (such as
+// `db.*` for the `db` and `db.pool` loggers), and `*` matches all loggers. When
+// multiple patterns match a logger, an exact name wins over any `.*` pattern,
+// the longest `.*` pattern wins over shorter ones, and `*` is used last.
+type LevelRegistry struct {
+	mutex     sync.RWMutex
+	overrides map[string]levelOverride
+
+	// now is used to get the current time, and can be replaced in tests.
+	now func() time.Time
+}
+
+type levelOverride struct {
+	level   zapcore.Level
+	expires time.Time
+}
+
+// NewLevelRegistry returns an empty LevelRegistry.
+func NewLevelRegistry() *LevelRegistry {
+	return &LevelRegistry{
+		overrides: map[string]levelOverride{},
+		now:       time.Now,
+	}
+}
+
+// SetLevel overrides the level of the loggers matching the pattern. If ttl is
+// not zero, the override is removed after it expires.
+func (r *LevelRegistry) SetLevel(pattern string, level zapcore.Level, ttl time.Duration) {
+	override := levelOverride{level: level}
+	if ttl > 0 {
+		override.expires = r.now().Add(ttl)
+	}
+
+	r.mutex.Lock()
+	r.overrides[pattern] = override
+	r.mutex.Unlock()
+}
+
+// Unset removes the override of the pattern.
+func (r *LevelRegistry) Unset(pattern string) {
+	r.mutex.Lock()
+	delete(r.overrides, pattern)
+	r.mutex.Unlock()
+}
+
+// Level returns the level of the override that applies to the logger with the
+// given name. The second return value is false if there is none.
+func (r *LevelRegistry) Level(name string) (zapcore.Level, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	now := r.now()
+	best, found := "", false
+	var level zapcore.Level
+
+	for pattern, override := range r.overrides {
+		if override.expired(now) || !matchLoggerName(pattern, name) {
+			continue
+		}
+
+		if !found || morePreciseLoggerPattern(pattern, best) {
+			best, level, found = pattern, override.level, true
+		}
+	}
+
+	return level, found
+}
+
+// enabled reports whether any of the overrides enables the level.
+func (r *LevelRegistry) enabled(level zapcore.Level) bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	now := r.now()
+	for _, override := range r.overrides {
+		if !override.expired(now) && override.level.Enabled(level) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (o levelOverride) expired(now time.Time) bool {
+	return !o.expires.IsZero() && !now.Before(o.expires)
+}
+
+// morePreciseLoggerPattern reports whether pattern a is more precise than
+// pattern b, when both match the same logger name: an exact name is more
+// precise than a `.*` pattern, which is more precise than `*`. Of two `.*`
+// patterns, the longest is the most precise.
+func morePreciseLoggerPattern(a, b string) bool {
+	if rankA, rankB := loggerPatternRank(a), loggerPatternRank(b); rankA != rankB {
+		return rankA > rankB
+	}
+
+	return len(a) > len(b)
+}
+
+func loggerPatternRank(pattern string) int {
+	switch {
+	case pattern == "*":
+		return 0
+	case strings.HasSuffix(pattern, ".*"):
+		return 1
+	default:
+		return 2
+	}
+}
+
+func matchLoggerName(pattern, name string) bool {
+	switch {
+	case pattern == "*":
+		return true
+	case strings.HasSuffix(pattern, ".*"):
+		prefix := strings.TrimSuffix(pattern, ".*")
+		return name == prefix || strings.HasPrefix(name, prefix+".")
+	default:
+		return pattern == name
+	}
+}
+
+// levelPayload is the JSON representation of an override.
+type levelPayload struct {
+	Logger  string         `json:"logger"`
+	Level   *zapcore.Level `json:"level"`
+	TTL     string         `json:"ttl,omitempty"`
+	Expires *time.Time     `json:"expires,omitempty"`
+}
+
+// ServeHTTP implements http.Handler, to inspect and change the overrides as
+// JSON:
+//
+//	GET     lists all overrides
+//	PUT     sets an override, such as {"logger": "db.*", "level": "debug", "ttl": "10m"}
+//	DELETE  removes the override of the `logger` query parameter
+func (r *LevelRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		writeLevelJSON(w, http.StatusOK, r.list())
+
+	case http.MethodPut:
+		var payload levelPayload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			writeLevelError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if payload.Logger == "" || payload.Level == nil {
+			writeLevelError(w, http.StatusBadRequest, errors.New("logger and level are required"))
+			return
+		}
+
+		var ttl time.Duration
+		if payload.TTL != "" {
+			var err error
+			if ttl, err = time.ParseDuration(payload.TTL); err != nil {
+				writeLevelError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		r.SetLevel(payload.Logger, *payload.Level, ttl)
+		writeLevelJSON(w, http.StatusOK, r.list())
+
+	case http.MethodDelete:
+		logger := req.URL.Query().Get("logger")
+		if logger == "" {
+			writeLevelError(w, http.StatusBadRequest, errors.New("logger is required"))
+			return
+		}
+
+		r.Unset(logger)
+		writeLevelJSON(w, http.StatusOK, r.list())
+
+	default:
+		writeLevelError(w, http.StatusMethodNotAllowed, errors.New("only GET, PUT and DELETE are supported"))
+	}
+}
+
+// list returns all active overrides, sorted by logger name pattern.
+func (r *LevelRegistry) list() []levelPayload {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	now := r.now()
+	out := []levelPayload{}
+	for pattern, override := range r.overrides {
+		if override.expired(now) {
+			continue
+		}
+
+		level := override.level
+		payload := levelPayload{Logger: pattern, Level: &level}
+		if !override.expires.IsZero() {
+			expires := override.expires
+			payload.Expires = &expires
+		}
+
+		out = append(out, payload)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Logger < out[j].Logger
+	})
+
+	return out
+}
+
+func writeLevelJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v) // nolint: errcheck
+}
+
+func writeLevelError(w http.ResponseWriter, status int, err error) {
+	writeLevelJSON(w, status, map[string]string{"error": err.Error()})
+}
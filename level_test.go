@@ -0,0 +1,211 @@
+package zapdriver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestMatchLoggerName(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"db", "db", true},
+		{"db", "db.pool", false},
+		{"db.*", "db", true},
+		{"db.*", "db.pool", true},
+		{"db.*", "db.pool.conn", true},
+		{"db.*", "dbx", false},
+		{"*", "", true},
+		{"*", "http", true},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, matchLoggerName(tt.pattern, tt.name), tt.pattern+" "+tt.name)
+	}
+}
+
+func TestLevelRegistry(t *testing.T) {
+	now := time.Date(2019, 6, 1, 10, 0, 0, 0, time.UTC)
+	r := NewLevelRegistry()
+	r.now = func() time.Time { return now }
+
+	_, ok := r.Level("db")
+	assert.False(t, ok)
+
+	r.SetLevel("*", zapcore.WarnLevel, 0)
+	r.SetLevel("db.*", zapcore.DebugLevel, time.Minute)
+	r.SetLevel("db.pool", zapcore.ErrorLevel, 0)
+
+	level, _ := r.Level("http")
+	assert.Equal(t, zapcore.WarnLevel, level)
+	level, _ = r.Level("db.query")
+	assert.Equal(t, zapcore.DebugLevel, level)
+	level, _ = r.Level("db.pool")
+	assert.Equal(t, zapcore.ErrorLevel, level)
+
+	assert.True(t, r.enabled(zapcore.DebugLevel))
+
+	now = now.Add(time.Minute)
+	level, _ = r.Level("db.query")
+	assert.Equal(t, zapcore.WarnLevel, level, "expired overrides are ignored")
+	assert.False(t, r.enabled(zapcore.DebugLevel))
+
+	r.Unset("*")
+	_, ok = r.Level("http")
+	assert.False(t, ok)
+}
+
+func TestLevelRegistry_ExactBeatsWildcard(t *testing.T) {
+	r := NewLevelRegistry()
+	r.SetLevel("*", zapcore.WarnLevel, 0)
+	r.SetLevel("db.*", zapcore.DebugLevel, 0)
+	r.SetLevel("db.x", zapcore.ErrorLevel, 0)
+
+	// Map iteration order is random, so check a few times.
+	for i := 0; i < 100; i++ {
+		level, _ := r.Level("db.x")
+		require.Equal(t, zapcore.ErrorLevel, level, "exact name beats an equally long .* pattern")
+
+		level, _ = r.Level("db.y")
+		require.Equal(t, zapcore.DebugLevel, level)
+	}
+}
+
+func TestLevelRegistry_ServeHTTP(t *testing.T) {
+	r := NewLevelRegistry()
+	r.now = func() time.Time { return time.Date(2019, 6, 1, 10, 0, 0, 0, time.UTC) }
+
+	do := func(method, target, body string) (int, string) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+
+		return rec.Code, strings.TrimSpace(rec.Body.String())
+	}
+
+	code, body := do(http.MethodGet, "/", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "[]", body)
+
+	code, _ = do(http.MethodPut, "/", `{"logger": "db.*", "level": "debug", "ttl": "10m"}`)
+	assert.Equal(t, http.StatusOK, code)
+	code, body = do(http.MethodPut, "/", `{"logger": "http", "level": "error"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `[
+		{"logger": "db.*", "level": "debug", "expires": "2019-06-01T10:10:00Z"},
+		{"logger": "http", "level": "error"}
+	]`, body)
+
+	code, body = do(http.MethodDelete, "/?logger=db.*", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `[{"logger": "http", "level": "error"}]`, body)
+
+	for _, tt := range []struct{ method, body string }{
+		{http.MethodPut, `{"logger": "db", "level": "loud"}`},
+		{http.MethodPut, `{"logger": "db"}`},
+		{http.MethodPut, `{"logger": "db", "level": "info", "ttl": "soon"}`},
+		{http.MethodDelete, ``},
+	} {
+		code, body = do(tt.method, "/", tt.body)
+		assert.Equal(t, http.StatusBadRequest, code, tt.body)
+		assert.Contains(t, body, `"error"`)
+	}
+
+	code, _ = do(http.MethodPost, "/", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+}
+
+func TestWriteLevelOverrides(t *testing.T) {
+	registry := NewLevelRegistry()
+
+	infocore, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(infocore, WrapCore(LevelOverrides(registry)))
+
+	db := logger.Named("db")
+	pool := db.Named("pool")
+
+	logger.Debug("root debug")
+	db.Debug("db debug")
+	require.Equal(t, 0, logs.Len())
+
+	registry.SetLevel("db.*", zapcore.DebugLevel, 0)
+	registry.SetLevel("http", zapcore.ErrorLevel, 0)
+
+	logger.Debug("root debug")
+	db.Debug("db debug")
+	pool.With(zap.String("foo", "bar")).Debug("pool debug")
+	logger.Named("http").Warn("http warning")
+	logger.Named("http").Error("http error")
+	logger.Info("root info")
+
+	var messages []string
+	for _, entry := range logs.All() {
+		messages = append(messages, entry.Message)
+	}
+
+	assert.Equal(t, []string{"db debug", "pool debug", "http error", "root info"}, messages)
+}
+
+func TestWriteLevelOverrides_StartOperation(t *testing.T) {
+	registry := NewLevelRegistry()
+	registry.SetLevel("db", zapcore.ErrorLevel, 0)
+	registry.SetLevel("other", zapcore.DebugLevel, 0)
+
+	infocore, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(infocore, WrapCore(LevelOverrides(registry)))
+
+	op := StartOperation(logger.Named("db"), "id", "producer")
+	op.Debug("db debug")
+	op.Info("db info")
+	op.Error("db error")
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "db error", logs.All()[0].Message)
+}
+
+// enabledCore is a wrapping core which, unlike the zap cores, only consults
+// Enabled in Check.
+type enabledCore struct {
+	zapcore.Core
+}
+
+func (c enabledCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+
+	return ce
+}
+
+func TestWriteLevelOverrides_EnabledWrapper(t *testing.T) {
+	registry := NewLevelRegistry()
+	registry.SetLevel("db", zapcore.ErrorLevel, 0)
+	registry.SetLevel("other", zapcore.DebugLevel, 0)
+
+	infocore, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(infocore, WrapCore(LevelOverrides(registry)), zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return enabledCore{Core: c}
+	}))
+
+	logger.Named("db").Debug("db debug")
+	logger.Named("db").Info("db info")
+	logger.Named("db").Error("db error")
+	logger.Named("other").Debug("other debug")
+	logger.Debug("root debug")
+
+	var messages []string
+	for _, entry := range logs.All() {
+		messages = append(messages, entry.Message)
+	}
+
+	assert.Equal(t, []string{"db error", "other debug"}, messages)
+}
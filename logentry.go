@@ -0,0 +1,211 @@
+package zapdriver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// LogEntry is a single structured log entry, as written by Zapdriver and parsed
+// by Stackdriver.
+//
+// see: https://cloud.google.com/logging/docs/structured-logging
+type LogEntry struct {
+	Severity       string
+	Timestamp      time.Time
+	Message        string
+	Labels         map[string]string
+	SourceLocation *LogEntrySourceLocation
+	HTTPRequest    *HTTPPayload
+	Operation      *LogEntryOperation
+	Trace          string
+	SpanID         string
+	TraceSampled   bool
+	InsertID       string
+	ServiceContext *LogEntryServiceContext
+	Context        *LogEntryErrorContext
+
+	// Payload contains all other fields of the entry.
+	Payload map[string]interface{}
+}
+
+// LogEntrySourceLocation is the source code location of a LogEntry.
+type LogEntrySourceLocation struct {
+	File     string `json:"file"`
+	Line     string `json:"line"`
+	Function string `json:"function"`
+}
+
+// LogEntryOperation is the operation a LogEntry belongs to.
+type LogEntryOperation struct {
+	ID       string `json:"id"`
+	Producer string `json:"producer"`
+	First    bool   `json:"first"`
+	Last     bool   `json:"last"`
+}
+
+// LogEntryServiceContext is the service that produced a LogEntry.
+type LogEntryServiceContext struct {
+	Service string `json:"service"`
+	Version string `json:"version,omitempty"`
+}
+
+// LogEntryErrorContext is the context of a LogEntry that is reported to Error
+// Reporting.
+type LogEntryErrorContext struct {
+	ReportLocation   *LogEntryReportLocation   `json:"reportLocation"`
+	SourceReferences []LogEntrySourceReference `json:"sourceReferences,omitempty"`
+}
+
+// LogEntryReportLocation is the source code location of a reported error.
+type LogEntryReportLocation struct {
+	FilePath     string `json:"filePath"`
+	LineNumber   string `json:"lineNumber"`
+	FunctionName string `json:"functionName"`
+}
+
+// LogEntrySourceReference is the source code revision of a reported error.
+type LogEntrySourceReference struct {
+	Repository string `json:"repository,omitempty"`
+	RevisionID string `json:"revisionId"`
+}
+
+// fields returns pointers to the fields of the entry, by their JSON key.
+func (e *LogEntry) fields() map[string]interface{} {
+	return map[string]interface{}{
+		encoderConfig.LevelKey:   &e.Severity,
+		encoderConfig.TimeKey:    &e.Timestamp,
+		encoderConfig.MessageKey: &e.Message,
+		labelsKey:                &e.Labels,
+		sourceKey:                &e.SourceLocation,
+		"httpRequest":            &e.HTTPRequest,
+		operationKey:             &e.Operation,
+		traceKey:                 &e.Trace,
+		spanKey:                  &e.SpanID,
+		traceSampledKey:          &e.TraceSampled,
+		insertIDKey:              &e.InsertID,
+		serviceContextKey:        &e.ServiceContext,
+		contextKey:               &e.Context,
+	}
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (e *LogEntry) UnmarshalJSON(b []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	*e = LogEntry{}
+	fields := e.fields()
+
+	for key, value := range raw {
+		if field, ok := fields[key]; ok {
+			if err := json.Unmarshal(value, field); err != nil {
+				return fmt.Errorf("zapdriver: decoding %q: %v", key, err)
+			}
+
+			continue
+		}
+
+		var v interface{}
+		if err := json.Unmarshal(value, &v); err != nil {
+			return err
+		}
+
+		if e.Payload == nil {
+			e.Payload = map[string]interface{}{}
+		}
+		e.Payload[key] = v
+	}
+
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. It encodes the entry in the same
+// format as Zapdriver writes it, omitting empty fields.
+func (e LogEntry) MarshalJSON() ([]byte, error) {
+	out := make(map[string]interface{}, len(e.Payload)+13)
+	for k, v := range e.Payload {
+		out[k] = v
+	}
+
+	set := func(key string, value interface{}, empty bool) {
+		if !empty {
+			out[key] = value
+		}
+	}
+
+	set(encoderConfig.LevelKey, e.Severity, e.Severity == "")
+	set(encoderConfig.TimeKey, e.Timestamp.Format(time.RFC3339Nano), e.Timestamp.IsZero())
+	set(encoderConfig.MessageKey, e.Message, e.Message == "")
+	set(labelsKey, e.Labels, len(e.Labels) == 0)
+	set(sourceKey, e.SourceLocation, e.SourceLocation == nil)
+	set("httpRequest", e.HTTPRequest, e.HTTPRequest == nil)
+	set(operationKey, e.Operation, e.Operation == nil)
+	set(traceKey, e.Trace, e.Trace == "")
+	set(spanKey, e.SpanID, e.SpanID == "")
+	set(traceSampledKey, e.TraceSampled, !e.TraceSampled)
+	set(insertIDKey, e.InsertID, e.InsertID == "")
+	set(serviceContextKey, e.ServiceContext, e.ServiceContext == nil)
+	set(contextKey, e.Context, e.Context == nil)
+
+	return json.Marshal(out)
+}
+
+// DecodeError is returned by LogEntryDecoder when a line is not a valid JSON
+// log entry.
+type DecodeError struct {
+	// LineNumber is the 1-based number of the line that could not be decoded.
+	LineNumber int
+
+	// Line is the line that could not be decoded, without its line ending.
+	Line []byte
+
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("zapdriver: line %d: %v", e.LineNumber, e.Err)
+}
+
+// LogEntryDecoder reads and decodes newline-delimited JSON log entries from an
+// input stream.
+type LogEntryDecoder struct {
+	r    *bufio.Reader
+	line int
+}
+
+// NewLogEntryDecoder returns a new decoder that reads from r.
+func NewLogEntryDecoder(r io.Reader) *LogEntryDecoder {
+	return &LogEntryDecoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next log entry from the input and stores it in e. Empty
+// lines are skipped. It returns io.EOF when there are no more entries.
+//
+// If a line is not a valid log entry, a *DecodeError containing the line is
+// returned, and decoding can continue with the next line.
+func (d *LogEntryDecoder) Decode(e *LogEntry) error {
+	for {
+		line, err := d.r.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return err
+		}
+		d.line++
+
+		line = bytes.TrimRight(line, "\r\n")
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		if err := json.Unmarshal(line, e); err != nil {
+			return &DecodeError{LineNumber: d.line, Line: line, Err: err}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,136 @@
+package zapdriver_test
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blendle/zapdriver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const logEntryJSON = `{
+	"severity": "ERROR",
+	"timestamp": "2018-04-09T12:43:12.000678359Z",
+	"message": "hello world",
+	"logging.googleapis.com/labels": {"one": "1"},
+	"logging.googleapis.com/sourceLocation": {"file": "main.go", "line": "12", "function": "main.main"},
+	"httpRequest": {"requestMethod": "GET", "status": 200},
+	"logging.googleapis.com/operation": {"id": "id", "producer": "producer", "first": true, "last": false},
+	"logging.googleapis.com/trace": "projects/my-project/traces/abc",
+	"logging.googleapis.com/spanId": "0",
+	"logging.googleapis.com/trace_sampled": true,
+	"logging.googleapis.com/insertId": "def",
+	"serviceContext": {"service": "my service"},
+	"context": {"reportLocation": {"filePath": "main.go", "lineNumber": "12", "functionName": "main.main"}},
+	"hello": "universe",
+	"count": 3
+}`
+
+var logEntry = zapdriver.LogEntry{
+	Severity:       "ERROR",
+	Timestamp:      time.Date(2018, 4, 9, 12, 43, 12, 678359, time.UTC),
+	Message:        "hello world",
+	Labels:         map[string]string{"one": "1"},
+	SourceLocation: &zapdriver.LogEntrySourceLocation{File: "main.go", Line: "12", Function: "main.main"},
+	HTTPRequest:    &zapdriver.HTTPPayload{RequestMethod: "GET", Status: 200},
+	Operation:      &zapdriver.LogEntryOperation{ID: "id", Producer: "producer", First: true},
+	Trace:          "projects/my-project/traces/abc",
+	SpanID:         "0",
+	TraceSampled:   true,
+	InsertID:       "def",
+	ServiceContext: &zapdriver.LogEntryServiceContext{Service: "my service"},
+	Context: &zapdriver.LogEntryErrorContext{
+		ReportLocation: &zapdriver.LogEntryReportLocation{FilePath: "main.go", LineNumber: "12", FunctionName: "main.main"},
+	},
+	Payload: map[string]interface{}{"hello": "universe", "count": float64(3)},
+}
+
+func TestLogEntry_UnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	var got zapdriver.LogEntry
+	require.NoError(t, json.Unmarshal([]byte(logEntryJSON), &got))
+
+	assert.True(t, logEntry.Timestamp.Equal(got.Timestamp))
+	got.Timestamp = logEntry.Timestamp
+	assert.Equal(t, logEntry, got)
+}
+
+func TestLogEntry_UnmarshalJSON_InvalidField(t *testing.T) {
+	t.Parallel()
+
+	var got zapdriver.LogEntry
+	err := json.Unmarshal([]byte(`{"logging.googleapis.com/labels": {"one": 1}}`), &got)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "logging.googleapis.com/labels")
+}
+
+func TestLogEntry_MarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	b, err := json.Marshal(logEntry)
+	require.NoError(t, err)
+
+	var got zapdriver.LogEntry
+	require.NoError(t, json.Unmarshal(b, &got))
+	assert.Equal(t, logEntry, got)
+
+	b, err = json.Marshal(zapdriver.LogEntry{Message: "hello world"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"message": "hello world"}`, string(b))
+}
+
+func TestLogEntryDecoder(t *testing.T) {
+	t.Parallel()
+
+	input := strings.Join([]string{
+		`{"severity": "INFO", "message": "one"}`,
+		``,
+		`not json`,
+		`{"severity": "DEBUG", "message": "two"}`,
+	}, "\n")
+
+	dec := zapdriver.NewLogEntryDecoder(strings.NewReader(input))
+
+	var entry zapdriver.LogEntry
+	require.NoError(t, dec.Decode(&entry))
+	assert.Equal(t, "one", entry.Message)
+
+	err := dec.Decode(&entry)
+	require.IsType(t, &zapdriver.DecodeError{}, err)
+	assert.Equal(t, 3, err.(*zapdriver.DecodeError).LineNumber)
+	assert.Equal(t, "not json", string(err.(*zapdriver.DecodeError).Line))
+
+	require.NoError(t, dec.Decode(&entry))
+	assert.Equal(t, "DEBUG", entry.Severity)
+	assert.Equal(t, "two", entry.Message)
+
+	assert.Equal(t, io.EOF, dec.Decode(&entry))
+}
+
+func TestLogEntryDecoder_ZapdriverOutput(t *testing.T) {
+	t.Parallel()
+
+	buf := &strings.Builder{}
+	logger := zapdriverLogger(buf)
+	logger.Info("hello world", zapdriver.Label("one", "1"))
+	logger.Warn("hello universe")
+
+	dec := zapdriver.NewLogEntryDecoder(strings.NewReader(buf.String()))
+
+	var entry zapdriver.LogEntry
+	require.NoError(t, dec.Decode(&entry))
+	assert.Equal(t, "INFO", entry.Severity)
+	assert.Equal(t, map[string]string{"one": "1"}, entry.Labels)
+	require.NotNil(t, entry.SourceLocation)
+	assert.Contains(t, entry.SourceLocation.File, "zapdriver/logentry_test.go")
+
+	require.NoError(t, dec.Decode(&entry))
+	assert.Equal(t, "WARNING", entry.Severity)
+	assert.Equal(t, io.EOF, dec.Decode(&entry))
+}
@@ -1,9 +1,13 @@
 package zapdriver
 
 import (
+	"context"
+
 	"go.uber.org/zap"
 )
 
+type loggerContextKey struct{}
+
 // NewProduction builds a sensible production Logger that writes InfoLevel and
 // above logs to standard error as JSON.
 //
@@ -37,3 +41,18 @@ func NewDevelopmentWithCore(core zap.Option, options ...zap.Option) (*zap.Logger
 
 	return NewDevelopmentConfig().Build(options...)
 }
+
+// ContextWithLogger returns a copy of ctx which carries the logger.
+func ContextWithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// LoggerFromContext returns the logger carried by ctx, or a no-op logger if
+// there is none.
+func LoggerFromContext(ctx context.Context) *zap.Logger {
+	if logger, ok := ctx.Value(loggerContextKey{}).(*zap.Logger); ok {
+		return logger
+	}
+
+	return zap.NewNop()
+}
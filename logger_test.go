@@ -1,6 +1,7 @@
 package zapdriver
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -42,3 +43,14 @@ func TestNewDevelopmentWithCore(t *testing.T) {
 	require.NoError(t, err)
 	assert.IsType(t, &zap.Logger{}, logger)
 }
+
+func TestLoggerFromContext(t *testing.T) {
+	t.Parallel()
+
+	assert.NotNil(t, LoggerFromContext(context.Background()))
+
+	logger := zap.NewExample()
+	ctx := ContextWithLogger(context.Background(), logger)
+
+	assert.Equal(t, logger, LoggerFromContext(ctx))
+}
@@ -0,0 +1,289 @@
+package zapdriver
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	metricKey     = "metric"
+	metricMessage = "metric"
+
+	counterKind      = "counter"
+	distributionKind = "distribution"
+)
+
+// metricsConfig is used to configure Metrics.
+type metricsConfig struct {
+	// Interval is the time between two aggregated summary entries. If zero,
+	// every measurement is logged as a separate entry.
+	Interval time.Duration
+}
+
+// MetricAggregation aggregates the measurements in memory, and logs a single
+// summary entry per metric and label set every interval, instead of an entry
+// per measurement.
+func MetricAggregation(interval time.Duration) func(*metricsConfig) {
+	return func(c *metricsConfig) {
+		c.Interval = interval
+	}
+}
+
+// Metrics logs measurements in a stable schema, to be extracted by log-based
+// metrics in Cloud Monitoring.
+//
+// Every entry has the message "metric", and a `metric` field with the `name`,
+// `kind` and `value` of the measurement, so a log-based metric can be defined
+// with the filter `jsonPayload.metric.name="orders_placed"` and the value
+// extractor `jsonPayload.metric.value`. The labels of the measurement are
+// added as regular entry labels.
+//
+// see: https://cloud.google.com/logging/docs/logs-based-metrics
+type Metrics struct {
+	config metricsConfig
+
+	// logger logs measurements as they are made, skipping the frames of this
+	// package. flushLogger logs the aggregated summaries.
+	logger      *zap.Logger
+	flushLogger *zap.Logger
+
+	mutex      sync.Mutex
+	aggregates map[string]*metricAggregate
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewMetrics returns a new Metrics logging to logger. If aggregation is
+// enabled, call `Close` to log the remaining summaries before exiting the
+// application.
+func NewMetrics(logger *zap.Logger, options ...func(*metricsConfig)) *Metrics {
+	m := &Metrics{
+		logger:      logger.WithOptions(zap.AddCallerSkip(2)),
+		flushLogger: logger,
+		aggregates:  map[string]*metricAggregate{},
+		done:        make(chan struct{}),
+	}
+	for _, option := range options {
+		option(&m.config)
+	}
+
+	if m.config.Interval > 0 {
+		m.wg.Add(1)
+		go m.run()
+	}
+
+	return m
+}
+
+// Counter returns a counter with the given name. The labels are added to every
+// measurement of the counter.
+func (m *Metrics) Counter(name string, labels ...zap.Field) *Counter {
+	return &Counter{metrics: m, name: name, labels: labels}
+}
+
+// Distribution returns a distribution with the given name. The labels are
+// added to every measurement of the distribution.
+func (m *Metrics) Distribution(name string, labels ...zap.Field) *Distribution {
+	return &Distribution{metrics: m, name: name, labels: labels}
+}
+
+// Flush logs the aggregated summaries of all measurements made since the
+// previous flush. It does nothing if aggregation is disabled.
+func (m *Metrics) Flush() {
+	m.mutex.Lock()
+	aggregates := m.aggregates
+	m.aggregates = map[string]*metricAggregate{}
+	m.mutex.Unlock()
+
+	keys := make([]string, 0, len(aggregates))
+	for key := range aggregates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		a := aggregates[key]
+		fields := append([]zap.Field{zap.Object(metricKey, a)}, a.labels...)
+
+		m.flushLogger.Info(metricMessage, fields...)
+	}
+}
+
+// Close stops the background flusher, and logs the remaining aggregated
+// summaries.
+func (m *Metrics) Close() {
+	m.closeOnce.Do(func() { close(m.done) })
+	m.wg.Wait()
+
+	m.Flush()
+}
+
+// record logs a single measurement, or adds it to its aggregate.
+func (m *Metrics) record(kind, name string, value float64, fixed, labels []zap.Field) {
+	all := make([]zap.Field, 0, len(fixed)+len(labels))
+	all = append(all, fixed...)
+	all = append(all, labels...)
+
+	if m.config.Interval <= 0 {
+		fields := append([]zap.Field{zap.Object(metricKey, metricValue{kind, name, value})}, all...)
+		m.logger.Info(metricMessage, fields...)
+
+		return
+	}
+
+	key := metricAggregateKey(kind, name, all)
+
+	m.mutex.Lock()
+	a, ok := m.aggregates[key]
+	if !ok {
+		a = &metricAggregate{kind: kind, name: name, labels: all, min: value, max: value}
+		m.aggregates[key] = a
+	}
+	a.add(value)
+	m.mutex.Unlock()
+}
+
+// run periodically flushes the aggregates until Metrics is closed.
+func (m *Metrics) run() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.Flush()
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// Counter counts events, such as placed orders or failed logins.
+type Counter struct {
+	metrics *Metrics
+	name    string
+	labels  []zap.Field
+}
+
+// Inc counts a single event, with the given labels added to the counter's own
+// labels.
+func (c *Counter) Inc(labels ...zap.Field) {
+	c.metrics.record(counterKind, c.name, 1, c.labels, labels)
+}
+
+// Add counts n events, with the given labels added to the counter's own labels.
+func (c *Counter) Add(n int64, labels ...zap.Field) {
+	c.metrics.record(counterKind, c.name, float64(n), c.labels, labels)
+}
+
+// Distribution records measured values, such as request sizes or queue
+// latencies.
+type Distribution struct {
+	metrics *Metrics
+	name    string
+	labels  []zap.Field
+}
+
+// Observe records a single value, with the given labels added to the
+// distribution's own labels.
+func (d *Distribution) Observe(value float64, labels ...zap.Field) {
+	d.metrics.record(distributionKind, d.name, value, d.labels, labels)
+}
+
+// metricValue is the `metric` field of a single measurement.
+type metricValue struct {
+	kind  string
+	name  string
+	value float64
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+func (v metricValue) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("name", v.name)
+	enc.AddString("kind", v.kind)
+	addMetricValue(enc, v.kind, v.value)
+
+	return nil
+}
+
+// metricAggregate is the `metric` field of an aggregated summary. The value of
+// a counter is the number of counted events; the value of a distribution is the
+// mean of the observed values.
+type metricAggregate struct {
+	kind   string
+	name   string
+	labels []zap.Field
+
+	count int64
+	sum   float64
+	min   float64
+	max   float64
+}
+
+func (a *metricAggregate) add(value float64) {
+	a.count++
+	a.sum += value
+
+	if value < a.min {
+		a.min = value
+	}
+	if value > a.max {
+		a.max = value
+	}
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+func (a *metricAggregate) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("name", a.name)
+	enc.AddString("kind", a.kind)
+
+	if a.kind == counterKind {
+		addMetricValue(enc, a.kind, a.sum)
+		return nil
+	}
+
+	enc.AddFloat64("value", a.sum/float64(a.count))
+	enc.AddInt64("count", a.count)
+	enc.AddFloat64("sum", a.sum)
+	enc.AddFloat64("min", a.min)
+	enc.AddFloat64("max", a.max)
+
+	return nil
+}
+
+// addMetricValue adds the value of a measurement, as an integer for counters.
+func addMetricValue(enc zapcore.ObjectEncoder, kind string, value float64) {
+	if kind == counterKind {
+		enc.AddInt64("value", int64(value))
+		return
+	}
+
+	enc.AddFloat64("value", value)
+}
+
+// metricAggregateKey identifies the aggregate of a metric and label set.
+func metricAggregateKey(kind, name string, labels []zap.Field) string {
+	values := map[string]string{}
+	for i := range labels {
+		if isLabelField(labels[i]) {
+			values[labels[i].Key] = labels[i].String
+		}
+	}
+
+	pairs := make([]string, 0, len(values))
+	for k, v := range values {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+
+	return kind + "\x00" + name + "\x00" + strings.Join(pairs, "\x00")
+}
@@ -0,0 +1,118 @@
+package zapdriver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestMetrics_Counter(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	metrics := NewMetrics(zap.New(core, zap.AddCaller()))
+
+	orders := metrics.Counter("orders_placed", Label("service", "shop"))
+	orders.Inc(Label("region", "eu"))
+	orders.Add(3)
+
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, "metric", entries[0].Message)
+	assert.Equal(t, zapcore.InfoLevel, entries[0].Level)
+	assert.Contains(t, entries[0].Caller.File, "zapdriver/metric_test.go")
+	assert.Equal(t, map[string]interface{}{
+		"metric":         map[string]interface{}{"name": "orders_placed", "kind": "counter", "value": int64(1)},
+		"labels.service": "shop",
+		"labels.region":  "eu",
+	}, entries[0].ContextMap())
+	assert.Equal(t, map[string]interface{}{
+		"metric":         map[string]interface{}{"name": "orders_placed", "kind": "counter", "value": int64(3)},
+		"labels.service": "shop",
+	}, entries[1].ContextMap())
+}
+
+func TestMetrics_Distribution(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	metrics := NewMetrics(zap.New(core))
+
+	metrics.Distribution("queue_latency").Observe(0.25, Label("queue", "emails"))
+
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 1)
+	assert.Equal(t, map[string]interface{}{
+		"metric":       map[string]interface{}{"name": "queue_latency", "kind": "distribution", "value": 0.25},
+		"labels.queue": "emails",
+	}, entries[0].ContextMap())
+}
+
+func TestMetrics_Aggregation(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	metrics := NewMetrics(zap.New(core), MetricAggregation(time.Hour))
+	defer metrics.Close()
+
+	orders := metrics.Counter("orders_placed")
+	orders.Inc(Label("region", "eu"))
+	orders.Add(2, Label("region", "eu"))
+	orders.Inc(Label("region", "us"))
+
+	latency := metrics.Distribution("queue_latency")
+	latency.Observe(1)
+	latency.Observe(4)
+	latency.Observe(1)
+
+	assert.Equal(t, 0, logs.Len())
+	metrics.Flush()
+
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 3)
+	assert.Equal(t, map[string]interface{}{
+		"metric":        map[string]interface{}{"name": "orders_placed", "kind": "counter", "value": int64(3)},
+		"labels.region": "eu",
+	}, entries[0].ContextMap())
+	assert.Equal(t, map[string]interface{}{
+		"metric":        map[string]interface{}{"name": "orders_placed", "kind": "counter", "value": int64(1)},
+		"labels.region": "us",
+	}, entries[1].ContextMap())
+	assert.Equal(t, map[string]interface{}{
+		"metric": map[string]interface{}{
+			"name":  "queue_latency",
+			"kind":  "distribution",
+			"value": 2.0,
+			"count": int64(3),
+			"sum":   6.0,
+			"min":   1.0,
+			"max":   4.0,
+		},
+	}, entries[2].ContextMap())
+
+	metrics.Flush()
+	assert.Equal(t, 3, logs.Len(), "nothing measured since the previous flush")
+}
+
+func TestMetrics_AggregationInterval(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	metrics := NewMetrics(zap.New(core), MetricAggregation(10*time.Millisecond))
+
+	metrics.Counter("orders_placed").Inc()
+
+	for i := 0; i < 100 && logs.Len() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	metrics.Close()
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "metric", logs.All()[0].Message)
+}
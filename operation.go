@@ -1,6 +1,11 @@
 package zapdriver
 
 import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -61,12 +66,169 @@ type operation struct {
 	Last bool `json:"last"`
 }
 
-// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+// MarshalLogObject implements zapcore.ObjectMarshaller interface. Unset fields
+// are omitted, unless the `KeepZeroFields` core option is used.
 func (op operation) MarshalLogObject(enc zapcore.ObjectEncoder) error {
-	enc.AddString("id", op.ID)
-	enc.AddString("producer", op.Producer)
-	enc.AddBool("first", op.First)
-	enc.AddBool("last", op.Last)
+	return op.marshalLogObject(enc, true)
+}
+
+func (op operation) marshalLogObject(enc zapcore.ObjectEncoder, omitZero bool) error {
+	e := zeroFieldsEncoder{enc, omitZero}
+
+	e.AddString("id", op.ID)
+	e.AddString("producer", op.Producer)
+	e.AddBool("first", op.First)
+	e.AddBool("last", op.Last)
 
 	return nil
 }
+
+type operationContextKey struct{}
+
+// OperationTracker logs entries that belong to a single operation, and manages
+// the `first` and `last` flags of the operation automatically.
+//
+// The embedded logger adds the operation to every entry, with `first` set to
+// true for the first entry that is encoded, so entries dropped by sampling
+// don't count. Call `End` to log the last entry of the operation.
+// An OperationTracker is safe for concurrent use.
+type OperationTracker struct {
+	*zap.Logger
+
+	id       string
+	producer string
+	start    time.Time
+
+	// started is set to 1 once the first entry of the operation is encoded.
+	started *int32
+
+	// endLogger is used by `End`, and skips the `End` frame when adding the
+	// caller to the entry.
+	endLogger *zap.Logger
+}
+
+// StartOperation returns a new OperationTracker for the operation with the
+// given id and producer. See `Operation` for more details on both arguments.
+func StartOperation(logger *zap.Logger, id, producer string) *OperationTracker {
+	t := &OperationTracker{
+		id:       id,
+		producer: producer,
+		start:    time.Now(),
+		started:  new(int32),
+	}
+
+	t.Logger = logger.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return &operationCore{Core: c, tracker: t}
+	}))
+	t.endLogger = t.Logger.WithOptions(zap.AddCallerSkip(1))
+
+	return t
+}
+
+// ID returns the id of the operation.
+func (t *OperationTracker) ID() string {
+	return t.id
+}
+
+// Producer returns the producer of the operation.
+func (t *OperationTracker) Producer() string {
+	return t.producer
+}
+
+// End logs the last entry of the operation at InfoLevel, with the time elapsed
+// since the start of the operation added as the `elapsed` field.
+func (t *OperationTracker) End(msg string, fields ...zap.Field) {
+	fields = append(fields,
+		zap.Duration("elapsed", time.Since(t.start)),
+		zap.Object(operationKey, &trackedOperation{tracker: t, last: true}),
+	)
+
+	t.endLogger.Info(msg, fields...)
+}
+
+// first returns true exactly once, for the first entry of the operation.
+func (t *OperationTracker) first() bool {
+	return atomic.CompareAndSwapInt32(t.started, 0, 1)
+}
+
+// ContextWithOperation returns a copy of ctx which carries the OperationTracker.
+func ContextWithOperation(ctx context.Context, t *OperationTracker) context.Context {
+	return context.WithValue(ctx, operationContextKey{}, t)
+}
+
+// OperationFromContext returns the OperationTracker carried by ctx, or nil if
+// there is none.
+func OperationFromContext(ctx context.Context) *OperationTracker {
+	t, _ := ctx.Value(operationContextKey{}).(*OperationTracker)
+
+	return t
+}
+
+// operationCore adds the operation of its tracker to every entry.
+type operationCore struct {
+	zapcore.Core
+
+	tracker *OperationTracker
+}
+
+// With adds structured context to the Core.
+func (c *operationCore) With(fields []zapcore.Field) zapcore.Core {
+	return &operationCore{Core: c.Core.With(fields), tracker: c.tracker}
+}
+
+// Check determines whether the supplied Entry should be logged, by delegating
+// to the wrapped core, so its level overrides or sampling still apply. If the
+// entry should be logged, the Core adds itself to the CheckedEntry and returns
+// the result.
+func (c *operationCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Core.Check(ent, nil) != nil {
+		return ce.AddCore(ent, c)
+	}
+
+	return ce
+}
+
+func (c *operationCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	// If the operation was manually set, don't overwrite it
+	for i := range fields {
+		if fields[i].Key == operationKey {
+			return c.Core.Write(ent, fields)
+		}
+	}
+
+	fields = append(fields, zap.Object(operationKey, &trackedOperation{tracker: c.tracker}))
+
+	return c.Core.Write(ent, fields)
+}
+
+// trackedOperation is the operation of an entry logged by an OperationTracker.
+// Whether it is the first entry of the operation is only decided when the entry
+// is encoded, so entries dropped after Check (such as by the `Sampling` core
+// option) don't use up the `first` flag.
+type trackedOperation struct {
+	tracker *OperationTracker
+	last    bool
+
+	once  sync.Once
+	first bool
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+func (op *trackedOperation) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return op.marshalLogObject(enc, true)
+}
+
+func (op *trackedOperation) marshalLogObject(enc zapcore.ObjectEncoder, omitZero bool) error {
+	// An entry can be encoded more than once, such as by a zapcore.NewTee core,
+	// so the flag is only decided for the first encoding.
+	op.once.Do(func() { op.first = op.tracker.first() })
+
+	o := operation{
+		ID:       op.tracker.id,
+		Producer: op.tracker.producer,
+		First:    op.first,
+		Last:     op.last,
+	}
+
+	return o.marshalLogObject(enc, omitZero)
+}
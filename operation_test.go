@@ -1,10 +1,18 @@
 package zapdriver
 
 import (
+	"context"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestOperation(t *testing.T) {
@@ -42,3 +50,127 @@ func TestOperationEnd(t *testing.T) {
 
 	assert.Equal(t, zap.Object(operationKey, op), field)
 }
+
+func TestOperation_MarshalLogObject(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		op   *operation
+		want string
+	}{
+		"start": {&operation{ID: "id", Producer: "producer", First: true}, `{"logging.googleapis.com/operation":{"id":"id","producer":"producer","first":true}}`},
+		"cont":  {&operation{ID: "id", Producer: "producer"}, `{"logging.googleapis.com/operation":{"id":"id","producer":"producer"}}`},
+		"end":   {&operation{ID: "id", Last: true}, `{"logging.googleapis.com/operation":{"id":"id","last":true}}`},
+	}
+
+	for name, tt := range tests {
+		assert.Equal(t, tt.want, encodeJSON(t, operationKey, tt.op), name)
+	}
+}
+
+func TestStartOperation(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	op := StartOperation(zap.New(core, zap.AddCaller()), "id", "producer")
+
+	op.Info("one")
+	op.With(zap.String("hello", "world")).Debug("two")
+	op.End("three")
+
+	require.Equal(t, 3, logs.Len())
+	entries := logs.All()
+
+	// The observer core encodes the fields lazily, in this order.
+	assert.Equal(t, map[string]interface{}{"id": "id", "producer": "producer", "first": true}, entries[0].ContextMap()[operationKey])
+	assert.Equal(t, map[string]interface{}{"id": "id", "producer": "producer"}, entries[1].ContextMap()[operationKey])
+	assert.Equal(t, "world", entries[1].ContextMap()["hello"])
+
+	assert.Equal(t, map[string]interface{}{"id": "id", "producer": "producer", "last": true}, entries[2].ContextMap()[operationKey])
+	assert.Contains(t, entries[2].ContextMap(), "elapsed")
+	assert.Contains(t, entries[2].Caller.File, "zapdriver/operation_test.go")
+}
+
+func TestStartOperation_EndOnly(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	StartOperation(zap.New(core), "id", "producer").End("done")
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t,
+		map[string]interface{}{"id": "id", "producer": "producer", "first": true, "last": true},
+		logs.All()[0].ContextMap()[operationKey],
+	)
+}
+
+func TestStartOperation_DelegatesCheck(t *testing.T) {
+	t.Parallel()
+
+	// The sampler drops all but the first entry with the same message, in its
+	// Check method.
+	core, logs := observer.New(zapcore.DebugLevel)
+	sampled := zapcore.NewSampler(core, time.Hour, 1, 100)
+	op := StartOperation(zap.New(sampled), "id", "producer")
+
+	op.Info("hello world")
+	op.Info("hello world")
+	op.Info("hello universe")
+
+	require.Equal(t, 2, logs.Len())
+	assert.Equal(t, "hello world", logs.All()[0].Message)
+	assert.Equal(t, "hello universe", logs.All()[1].Message)
+}
+
+func TestStartOperation_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	// Unlike the observer core, this core encodes the entries in Write.
+	buf := &zaptest.Buffer{}
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), zapcore.Lock(buf), zapcore.DebugLevel)
+	op := StartOperation(zap.New(core), "id", "producer")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			op.Info("hello world")
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, buf.Lines(), 10)
+	assert.Equal(t, 1, strings.Count(buf.String(), `"first":true`))
+}
+
+func TestStartOperation_Sampling(t *testing.T) {
+	t.Parallel()
+
+	buf := &zaptest.Buffer{}
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), buf, zapcore.DebugLevel)
+	sampler := NewSampler(time.Hour, 100, 100, SampleLevelRate(zapcore.DebugLevel, 0.5))
+	sampler.random = func() float64 { return 1 }
+	op := StartOperation(zap.New(core, WrapCore(Sampling(sampler))), "id", "producer")
+
+	op.Debug("dropped")
+	op.Info("one")
+	op.End("two")
+
+	lines := buf.Lines()
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"message":"one"`)
+	assert.Contains(t, lines[0], `"first":true`)
+	assert.NotContains(t, lines[1], `"first":true`)
+	assert.Contains(t, lines[1], `"last":true`)
+}
+
+func TestOperationContext(t *testing.T) {
+	t.Parallel()
+
+	op := StartOperation(zap.NewNop(), "id", "producer")
+	ctx := ContextWithOperation(context.Background(), op)
+
+	assert.Equal(t, op, OperationFromContext(ctx))
+	assert.Nil(t, OperationFromContext(context.Background()))
+}
@@ -0,0 +1,158 @@
+package zapdriver
+
+import (
+	"os"
+
+	"go.uber.org/zap"
+)
+
+// platform holds the defaults of a serverless or container platform.
+type platform struct {
+	// output is the path the logs are written to.
+	output string
+
+	// serviceEnv and versionEnv are the environment variables that contain the
+	// name and version of the service, in order of preference.
+	serviceEnv []string
+	versionEnv []string
+
+	// sampling enables sampling.
+	sampling bool
+
+	// syncAfterWrite syncs the output after every log.
+	syncAfterWrite bool
+}
+
+var (
+	cloudRun = platform{
+		output:     "stdout",
+		serviceEnv: []string{"K_SERVICE"},
+		versionEnv: []string{"K_REVISION"},
+		sampling:   true,
+	}
+
+	gke = platform{
+		output:     "stderr",
+		serviceEnv: []string{"CONTAINER_NAME"},
+		sampling:   true,
+	}
+
+	cloudFunctions = platform{
+		output:         "stdout",
+		serviceEnv:     []string{"K_SERVICE", "FUNCTION_NAME"},
+		versionEnv:     []string{"K_REVISION", "X_GOOGLE_FUNCTION_VERSION"},
+		syncAfterWrite: true,
+	}
+
+	appEngine = platform{
+		output:     "stdout",
+		serviceEnv: []string{"GAE_SERVICE"},
+		versionEnv: []string{"GAE_VERSION"},
+		sampling:   true,
+	}
+)
+
+// NewCloudRunConfig is a production logging configuration for Cloud Run.
+//
+// It writes to standard output, uses the service and revision as service name
+// and version, reports all errors, and adds the detected resource as labels.
+// The `ZAPDRIVER_*` environment variables, except `ZAPDRIVER_CONFIG`, override
+// these defaults.
+func NewCloudRunConfig() (Config, error) {
+	return newPlatformConfig(cloudRun, os.Getenv)
+}
+
+// NewCloudRun builds a production Logger for Cloud Run.
+//
+// It's a shortcut for NewCloudRunConfig().Build(...Option).
+func NewCloudRun(options ...zap.Option) (*zap.Logger, error) {
+	return buildPlatform(cloudRun, options)
+}
+
+// NewGKEConfig is a production logging configuration for Google Kubernetes
+// Engine.
+//
+// It writes to standard error, uses the `CONTAINER_NAME` environment variable
+// as service name, reports all errors, and adds the detected resource as
+// labels. The `ZAPDRIVER_*` environment variables, except `ZAPDRIVER_CONFIG`,
+// override these defaults.
+func NewGKEConfig() (Config, error) {
+	return newPlatformConfig(gke, os.Getenv)
+}
+
+// NewGKE builds a production Logger for Google Kubernetes Engine.
+//
+// It's a shortcut for NewGKEConfig().Build(...Option).
+func NewGKE(options ...zap.Option) (*zap.Logger, error) {
+	return buildPlatform(gke, options)
+}
+
+// NewCloudFunctionsConfig is a production logging configuration for Cloud
+// Functions.
+//
+// It writes to standard output, uses the function name and version as service
+// name and version, reports all errors, and adds the detected resource as
+// labels. Sampling is disabled, since functions log little per instance, and
+// the output is synced after every log, since the instance can be suspended as
+// soon as the function returns. The `ZAPDRIVER_*` environment variables, except
+// `ZAPDRIVER_CONFIG`, override these defaults.
+func NewCloudFunctionsConfig() (Config, error) {
+	return newPlatformConfig(cloudFunctions, os.Getenv)
+}
+
+// NewCloudFunctions builds a production Logger for Cloud Functions.
+//
+// It's a shortcut for NewCloudFunctionsConfig().Build(...Option).
+func NewCloudFunctions(options ...zap.Option) (*zap.Logger, error) {
+	return buildPlatform(cloudFunctions, options)
+}
+
+// NewAppEngineConfig is a production logging configuration for the App Engine
+// standard and flexible environments.
+//
+// It writes to standard output, uses the service and version as service name
+// and version, reports all errors, and adds the detected resource as labels.
+// The `ZAPDRIVER_*` environment variables, except `ZAPDRIVER_CONFIG`, override
+// these defaults.
+func NewAppEngineConfig() (Config, error) {
+	return newPlatformConfig(appEngine, os.Getenv)
+}
+
+// NewAppEngine builds a production Logger for App Engine.
+//
+// It's a shortcut for NewAppEngineConfig().Build(...Option).
+func NewAppEngine(options ...zap.Option) (*zap.Logger, error) {
+	return buildPlatform(appEngine, options)
+}
+
+func buildPlatform(p platform, options []zap.Option) (*zap.Logger, error) {
+	cfg, err := newPlatformConfig(p, os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg.Build(options...)
+}
+
+func newPlatformConfig(p platform, getenv func(string) string, options ...func(*resourceDetector)) (Config, error) {
+	cfg := NewConfig()
+	cfg.OutputPaths = []string{p.output}
+	cfg.ReportAllErrors = true
+	cfg.SyncAfterWrite = p.syncAfterWrite
+	if !p.sampling {
+		cfg.Sampling = nil
+	}
+
+	env := &resourceDetector{Getenv: getenv}
+	cfg.ServiceName = env.firstEnv(p.serviceEnv...)
+	cfg.ServiceVersion = env.firstEnv(p.versionEnv...)
+
+	cfg.Resource = DetectResource(append([]func(*resourceDetector){ResourceGetenv(getenv)}, options...)...)
+	cfg.ProjectID = cfg.Resource.Labels["project_id"]
+
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = cfg.Resource.Labels["container_name"]
+	}
+
+	return cfg, cfg.applyEnv(getenv)
+}
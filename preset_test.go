@@ -0,0 +1,93 @@
+package zapdriver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPlatformConfig(t *testing.T) {
+	t.Parallel()
+
+	metadata := newMetadataServer(map[string]string{
+		"/computeMetadata/v1/project/project-id": "my-project",
+		"/computeMetadata/v1/instance/region":    "projects/1/regions/europe-west4",
+		"/computeMetadata/v1/instance/zone":      "projects/1/zones/europe-west4-a",
+	})
+	defer metadata.Close()
+
+	var tests = map[string]struct {
+		platform platform
+		env      map[string]string
+		want     Config
+	}{
+		"cloud run": {
+			cloudRun,
+			map[string]string{"K_SERVICE": "my-service", "K_REVISION": "my-service-00001"},
+			Config{
+				ServiceName:    "my-service",
+				ServiceVersion: "my-service-00001",
+				ProjectID:      "my-project",
+				Resource:       &Resource{Type: "cloud_run_revision"},
+			},
+		},
+		"gke": {
+			gke,
+			map[string]string{"KUBERNETES_SERVICE_HOST": "10.0.0.1", "CONTAINER_NAME": "app"},
+			Config{ServiceName: "app", ProjectID: "my-project", Resource: &Resource{Type: "k8s_container"}},
+		},
+		"cloud functions": {
+			cloudFunctions,
+			map[string]string{"FUNCTION_TARGET": "Handle", "K_SERVICE": "my-function", "K_REVISION": "3"},
+			Config{
+				ServiceName:    "my-function",
+				ServiceVersion: "3",
+				ProjectID:      "my-project",
+				Resource:       &Resource{Type: "cloud_function"},
+				SyncAfterWrite: true,
+			},
+		},
+		"app engine": {
+			appEngine,
+			map[string]string{"GAE_SERVICE": "default", "GAE_VERSION": "20190601t100000"},
+			Config{
+				ServiceName:    "default",
+				ServiceVersion: "20190601t100000",
+				ProjectID:      "my-project",
+				Resource:       &Resource{Type: "gae_app"},
+			},
+		},
+		"environment overrides": {
+			cloudRun,
+			map[string]string{"K_SERVICE": "my-service", "ZAPDRIVER_SERVICE_NAME": "other", "ZAPDRIVER_PROJECT_ID": "other-project"},
+			Config{ServiceName: "other", ProjectID: "other-project", Resource: &Resource{Type: "cloud_run_revision"}},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := newPlatformConfig(tt.platform, getenv(tt.env), ResourceMetadataURL(metadata.URL+"/computeMetadata/v1/"), ResourceNamespaceFile(""))
+			require.NoError(t, err)
+
+			assert.Equal(t, []string{tt.platform.output}, cfg.OutputPaths)
+			assert.Equal(t, tt.platform.sampling, cfg.Sampling != nil)
+			assert.True(t, cfg.ReportAllErrors)
+			assert.Equal(t, tt.want.ServiceName, cfg.ServiceName)
+			assert.Equal(t, tt.want.ServiceVersion, cfg.ServiceVersion)
+			assert.Equal(t, tt.want.ProjectID, cfg.ProjectID)
+			assert.Equal(t, tt.want.Resource.Type, cfg.Resource.Type)
+			assert.Equal(t, tt.want.SyncAfterWrite, cfg.SyncAfterWrite)
+		})
+	}
+}
+
+func TestNewPlatformConfig_InvalidEnv(t *testing.T) {
+	t.Parallel()
+
+	metadata := newMetadataServer(map[string]string{})
+	defer metadata.Close()
+
+	_, err := newPlatformConfig(cloudRun, getenv(map[string]string{"ZAPDRIVER_LEVEL": "loud"}), ResourceMetadataURL(metadata.URL))
+	assert.Error(t, err)
+}
@@ -0,0 +1,344 @@
+package zapdriver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// redactedValue replaces masked values.
+const redactedValue = "[REDACTED]"
+
+// redactorConfig is used to configure a Redactor.
+type redactorConfig struct {
+	// KeyPatterns are regular expressions matched against field keys, label
+	// keys and query parameter names. Matching values are redacted entirely.
+	KeyPatterns []string
+
+	// ValuePatterns are regular expressions matched against string values. The
+	// matching parts of the values are redacted.
+	ValuePatterns []string
+
+	// Hash replaces redacted values with a hash instead of a mask.
+	Hash bool
+}
+
+// RedactKeys redacts the values of fields, labels and URL query parameters with
+// a key that matches any of the regular expressions, such as
+// `(?i)^(authorization|password)$`.
+func RedactKeys(patterns ...string) func(*redactorConfig) {
+	return func(c *redactorConfig) {
+		c.KeyPatterns = append(c.KeyPatterns, patterns...)
+	}
+}
+
+// RedactValues redacts the parts of string values that match any of the regular
+// expressions, such as e-mail addresses or access tokens.
+func RedactValues(patterns ...string) func(*redactorConfig) {
+	return func(c *redactorConfig) {
+		c.ValuePatterns = append(c.ValuePatterns, patterns...)
+	}
+}
+
+// RedactHash replaces redacted values with the first 16 hexadecimal characters
+// of their SHA-256 hash, prefixed with "sha256:", instead of "[REDACTED]". This
+// allows correlating entries with the same value, without logging the value.
+func RedactHash(hash bool) func(*redactorConfig) {
+	return func(c *redactorConfig) {
+		c.Hash = hash
+	}
+}
+
+// Redactor redacts personal data and secrets from log entries before they are
+// encoded. Use the `Redact()` core option to apply it to all entries.
+//
+// Key patterns apply to the keys of the top-level fields, the labels, and the
+// query parameters of the request URL and referer of the `HTTP()` field.
+//
+// Value patterns apply to the message, the labels, the request URL and referer
+// of the `HTTP()` field, and the top-level fields of these types: strings, byte
+// strings, string arrays (such as `zap.Strings`), errors and `fmt.Stringer`
+// values. Redacted errors and stringers are replaced by their redacted string,
+// so an error loses its type and verbose form.
+//
+// Values of other types are not checked against the value patterns, including
+// the fields of nested objects (other than the `HTTP()` field), other arrays,
+// reflected values (such as `zap.Any` with a struct) and binary fields.
+type Redactor struct {
+	keys   []*regexp.Regexp
+	values []*regexp.Regexp
+	hash   bool
+
+	redactions uint64
+}
+
+// NewRedactor returns a new Redactor. It returns an error if any of the
+// patterns is not a valid regular expression.
+func NewRedactor(options ...func(*redactorConfig)) (*Redactor, error) {
+	cfg := &redactorConfig{}
+	for _, option := range options {
+		option(cfg)
+	}
+
+	r := &Redactor{hash: cfg.Hash}
+	for _, pattern := range cfg.KeyPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("zapdriver: invalid key pattern: %v", err)
+		}
+
+		r.keys = append(r.keys, re)
+	}
+
+	for _, pattern := range cfg.ValuePatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("zapdriver: invalid value pattern: %v", err)
+		}
+
+		r.values = append(r.values, re)
+	}
+
+	return r, nil
+}
+
+// Redactions returns the number of values redacted so far.
+func (r *Redactor) Redactions() uint64 {
+	return atomic.LoadUint64(&r.redactions)
+}
+
+// fields returns a copy of fields, with all sensitive values redacted.
+func (r *Redactor) fields(fields []zapcore.Field) []zapcore.Field {
+	var out []zapcore.Field
+	for i := range fields {
+		f, ok := r.field(fields[i])
+		if !ok {
+			continue
+		}
+
+		if out == nil {
+			out = make([]zapcore.Field, len(fields))
+			copy(out, fields)
+		}
+		out[i] = f
+	}
+
+	if out == nil {
+		return fields
+	}
+
+	return out
+}
+
+// field returns the redacted field, and whether anything was redacted.
+func (r *Redactor) field(f zapcore.Field) (zapcore.Field, bool) {
+	if r.matchKey(f.Key) {
+		return zap.String(f.Key, r.replace(fieldString(f))), true
+	}
+
+	switch f.Type {
+	case zapcore.StringType:
+		if s, ok := r.value(f.String); ok {
+			return zap.String(f.Key, s), true
+		}
+
+	case zapcore.ByteStringType:
+		if b, ok := f.Interface.([]byte); ok {
+			if s, ok := r.value(string(b)); ok {
+				return zap.ByteString(f.Key, []byte(s)), true
+			}
+		}
+
+	case zapcore.StringerType:
+		if v, ok := f.Interface.(fmt.Stringer); ok && v != nil {
+			if s, ok := r.value(v.String()); ok {
+				return zap.String(f.Key, s), true
+			}
+		}
+
+	case zapcore.ErrorType:
+		if err, ok := f.Interface.(error); ok && err != nil {
+			if s, ok := r.value(err.Error()); ok {
+				return zap.NamedError(f.Key, errors.New(s)), true
+			}
+		}
+
+	case zapcore.ArrayMarshalerType:
+		if ss, ok := stringArray(f); ok {
+			redacted := false
+			for i := range ss {
+				if s, ok := r.value(ss[i]); ok {
+					ss[i], redacted = s, true
+				}
+			}
+
+			if redacted {
+				return zap.Strings(f.Key, ss), true
+			}
+		}
+
+	case zapcore.ObjectMarshalerType:
+		if p, ok := f.Interface.(*HTTPPayload); ok && p != nil {
+			if redacted, ok := r.http(p); ok {
+				return HTTP(redacted), true
+			}
+		}
+	}
+
+	return f, false
+}
+
+// message returns the redacted log message.
+func (r *Redactor) message(msg string) string {
+	if s, ok := r.value(msg); ok {
+		return s
+	}
+
+	return msg
+}
+
+// labels redacts the values of labels in place.
+func (r *Redactor) labels(l *labels) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for k, v := range l.store {
+		if r.matchKey(k) {
+			l.store[k] = r.replace(v)
+		} else if s, ok := r.value(v); ok {
+			l.store[k] = s
+		}
+	}
+}
+
+// http returns a copy of the payload with the query parameters of the request
+// URL and referer redacted.
+func (r *Redactor) http(p *HTTPPayload) (*HTTPPayload, bool) {
+	url, urlOK := r.url(p.RequestURL)
+	referer, refererOK := r.url(p.Referer)
+	if !urlOK && !refererOK {
+		return p, false
+	}
+
+	redacted := *p
+	redacted.RequestURL = url
+	redacted.Referer = referer
+
+	return &redacted, true
+}
+
+// url redacts the values of the query parameters with a sensitive name, and the
+// sensitive parts of the rest of the URL. The order and encoding of the other
+// parameters is kept as-is.
+func (r *Redactor) url(s string) (string, bool) {
+	redacted := false
+
+	base, query := s, ""
+	if i := strings.Index(s, "?"); i >= 0 {
+		base, query = s[:i], s[i+1:]
+	}
+
+	var fragment string
+	if i := strings.Index(query, "#"); i >= 0 {
+		query, fragment = query[:i], query[i:]
+	}
+
+	if query != "" {
+		params := strings.Split(query, "&")
+		for i, param := range params {
+			name := param
+			if j := strings.Index(param, "="); j >= 0 {
+				name = param[:j]
+			}
+
+			if r.matchKey(name) && name != param {
+				params[i] = name + "=" + r.replace(param[len(name)+1:])
+				redacted = true
+			}
+		}
+
+		s = base + "?" + strings.Join(params, "&") + fragment
+	}
+
+	if v, ok := r.value(s); ok {
+		s, redacted = v, true
+	}
+
+	return s, redacted
+}
+
+func (r *Redactor) matchKey(key string) bool {
+	for _, re := range r.keys {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// value redacts the parts of s that match any of the value patterns.
+func (r *Redactor) value(s string) (string, bool) {
+	redacted := false
+	for _, re := range r.values {
+		if !re.MatchString(s) {
+			continue
+		}
+
+		s = re.ReplaceAllStringFunc(s, r.replace)
+		redacted = true
+	}
+
+	return s, redacted
+}
+
+// replace returns the mask or hash of s, and counts the redaction.
+func (r *Redactor) replace(s string) string {
+	atomic.AddUint64(&r.redactions, 1)
+
+	if !r.hash {
+		return redactedValue
+	}
+
+	sum := sha256.Sum256([]byte(s))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// stringArray returns the elements of an array field, if they are all strings.
+func stringArray(f zapcore.Field) ([]string, bool) {
+	enc := zapcore.NewMapObjectEncoder()
+	f.AddTo(enc)
+
+	elems, ok := enc.Fields[f.Key].([]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	ss := make([]string, len(elems))
+	for i := range elems {
+		if ss[i], ok = elems[i].(string); !ok {
+			return nil, false
+		}
+	}
+
+	return ss, true
+}
+
+// fieldString returns the value of a field as a string.
+func fieldString(f zapcore.Field) string {
+	if f.Type == zapcore.StringType {
+		return f.String
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	f.AddTo(enc)
+
+	return fmt.Sprint(enc.Fields[f.Key])
+}
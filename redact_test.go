@@ -0,0 +1,143 @@
+package zapdriver
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const emailPattern = `[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`
+
+func TestNewRedactor_InvalidPattern(t *testing.T) {
+	_, err := NewRedactor(RedactKeys("("))
+	assert.Error(t, err)
+
+	_, err = NewRedactor(RedactValues("[a-"))
+	assert.Error(t, err)
+}
+
+func TestRedactor_Fields(t *testing.T) {
+	r, err := NewRedactor(RedactKeys(`(?i)^(authorization|password)$`), RedactValues(emailPattern))
+	require.NoError(t, err)
+
+	fields := []zapcore.Field{
+		zap.String("Authorization", "Bearer secret"),
+		zap.Int("password", 1234),
+		zap.String("user", "contact alice@example.com or bob@example.org"),
+		zap.String("other", "value"),
+		zap.Error(errors.New("alice@example.com")),
+	}
+
+	assert.Equal(t, []zapcore.Field{
+		zap.String("Authorization", "[REDACTED]"),
+		zap.String("password", "[REDACTED]"),
+		zap.String("user", "contact [REDACTED] or [REDACTED]"),
+		zap.String("other", "value"),
+		zap.Error(errors.New("[REDACTED]")),
+	}, r.fields(fields))
+
+	assert.Equal(t, "Bearer secret", fields[0].String, "original fields are not modified")
+	assert.Equal(t, uint64(5), r.Redactions())
+}
+
+func TestRedactor_FieldTypes(t *testing.T) {
+	r, err := NewRedactor(RedactValues(emailPattern))
+	require.NoError(t, err)
+
+	recipients := []string{"alice@example.com", "team"}
+	fields := []zapcore.Field{
+		zap.ByteString("body", []byte("to alice@example.com")),
+		zap.Stringer("addr", &url.URL{Scheme: "mailto", Opaque: "bob@example.com"}),
+		zap.Strings("recipients", recipients),
+		zap.Strings("groups", []string{"team"}),
+		zap.Ints("ids", []int{1, 2}),
+		zap.Any("nested", map[string]string{"email": "carol@example.com"}),
+	}
+
+	redacted := r.fields(fields)
+
+	assert.Equal(t, zap.ByteString("body", []byte("to [REDACTED]")), redacted[0])
+	assert.Equal(t, zap.String("addr", "mailto:[REDACTED]"), redacted[1])
+	assert.Equal(t, zap.Strings("recipients", []string{"[REDACTED]", "team"}), redacted[2])
+	assert.Equal(t, fields[3:], redacted[3:], "values without matches and other types are not changed")
+	assert.Equal(t, []string{"alice@example.com", "team"}, recipients, "original arrays are not modified")
+	assert.Equal(t, uint64(3), r.Redactions())
+}
+
+func TestRedactor_Hash(t *testing.T) {
+	r, err := NewRedactor(RedactKeys("token"), RedactHash(true))
+	require.NoError(t, err)
+
+	one := r.fields([]zapcore.Field{zap.String("token", "abc")})[0].String
+	two := r.fields([]zapcore.Field{zap.String("token", "abc")})[0].String
+	three := r.fields([]zapcore.Field{zap.String("token", "def")})[0].String
+
+	assert.Regexp(t, `^sha256:[0-9a-f]{16}$`, one)
+	assert.Equal(t, one, two)
+	assert.NotEqual(t, one, three)
+}
+
+func TestRedactor_Labels(t *testing.T) {
+	r, err := NewRedactor(RedactKeys("^email$"), RedactValues(`tok_[a-z0-9]+`))
+	require.NoError(t, err)
+
+	l := newLabels()
+	l.store = map[string]string{"email": "alice@example.com", "session": "id=tok_abc123", "tenant": "acme"}
+	r.labels(l)
+
+	assert.Equal(t, map[string]string{"email": "[REDACTED]", "session": "id=[REDACTED]", "tenant": "acme"}, l.store)
+}
+
+func TestRedactor_HTTP(t *testing.T) {
+	r, err := NewRedactor(RedactKeys("^(token|email)$"), RedactValues(emailPattern))
+	require.NoError(t, err)
+
+	payload := &HTTPPayload{
+		RequestMethod: "GET",
+		RequestURL:    "https://example.com/users/carol@example.com?b=1&token=abc%20def&a=2&email=&flag#frag",
+		Referer:       "https://example.com/login?token=xyz",
+	}
+
+	f := r.fields([]zapcore.Field{HTTP(payload)})[0]
+	redacted := f.Interface.(*HTTPPayload)
+
+	assert.Equal(t, "https://example.com/users/[REDACTED]?b=1&token=[REDACTED]&a=2&email=[REDACTED]&flag#frag", redacted.RequestURL)
+	assert.Equal(t, "https://example.com/login?token=[REDACTED]", redacted.Referer)
+	assert.Equal(t, "GET", redacted.RequestMethod)
+	assert.Equal(t, "https://example.com/login?token=xyz", payload.Referer, "original payload is not modified")
+
+	unchanged := HTTP(&HTTPPayload{RequestURL: "/foo?bar=baz"})
+	assert.Equal(t, unchanged, r.fields([]zapcore.Field{unchanged})[0])
+}
+
+func TestWriteRedact(t *testing.T) {
+	r, err := NewRedactor(RedactKeys("^(password|email)$"), RedactValues(emailPattern))
+	require.NoError(t, err)
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(Redact(r)))
+
+	logger.With(zap.String("password", "hunter2"), Label("email", "alice@example.com")).Info(
+		"hello dave@example.com",
+		zap.String("message", "sent to bob@example.com"),
+		zap.Error(errors.New("no mailbox for erin@example.com")),
+		Label("user", "carol@example.com"),
+	)
+
+	require.Equal(t, 1, logs.Len())
+	entry := logs.All()[0]
+	context := entry.ContextMap()
+
+	assert.Equal(t, "hello [REDACTED]", entry.Message)
+	assert.Equal(t, "[REDACTED]", context["password"])
+	assert.Equal(t, "sent to [REDACTED]", context["message"])
+	assert.Equal(t, "no mailbox for [REDACTED]", context["error"])
+	assert.Equal(t, map[string]interface{}{"email": "[REDACTED]", "user": "[REDACTED]"}, context[labelsKey])
+	assert.Equal(t, uint64(6), r.Redactions())
+}
@@ -18,6 +18,14 @@ func ErrorReport(pc uintptr, file string, line int, ok bool) zap.Field {
 	return zap.Object(contextKey, newReportContext(pc, file, line, ok))
 }
 
+// ErrorReportFromCaller adds the "context" field for getting the log line
+// reported as error, at the location of a caller of the function calling it.
+// The argument skip is the number of stack frames to ascend, with 0 identifying
+// the caller of ErrorReportFromCaller.
+func ErrorReportFromCaller(skip int) zap.Field {
+	return ErrorReport(runtime.Caller(skip + 1))
+}
+
 // reportLocation is the source code location information associated with the log entry
 // for the purpose of reporting an error,
 // if any.
@@ -27,23 +35,74 @@ type reportLocation struct {
 	Function string `json:"functionName"`
 }
 
-// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+// MarshalLogObject implements zapcore.ObjectMarshaller interface. Unset fields
+// are omitted, unless the `KeepZeroFields` core option is used.
 func (location reportLocation) MarshalLogObject(enc zapcore.ObjectEncoder) error {
-	enc.AddString("filePath", location.File)
-	enc.AddString("lineNumber", location.Line)
-	enc.AddString("functionName", location.Function)
+	return location.marshalLogObject(enc, true)
+}
+
+func (location reportLocation) marshalLogObject(enc zapcore.ObjectEncoder, omitZero bool) error {
+	e := zeroFieldsEncoder{enc, omitZero}
+
+	e.AddString("filePath", location.File)
+	e.AddString("lineNumber", location.Line)
+	e.AddString("functionName", location.Function)
+
+	return nil
+}
+
+// sourceReference is a reference to the source code revision of the service
+// that reported the error.
+type sourceReference struct {
+	Repository string `json:"repository"`
+	RevisionID string `json:"revisionId"`
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+func (reference sourceReference) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if reference.Repository != "" {
+		enc.AddString("repository", reference.Repository)
+	}
+	enc.AddString("revisionId", reference.RevisionID)
+
+	return nil
+}
+
+type sourceReferences []sourceReference
+
+// MarshalLogArray implements zapcore.ArrayMarshaler interface.
+func (references sourceReferences) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for i := range references {
+		if err := enc.AppendObject(references[i]); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
 // reportContext is the context information attached to a log for reporting errors
 type reportContext struct {
-	ReportLocation reportLocation `json:"reportLocation"`
+	ReportLocation   reportLocation   `json:"reportLocation"`
+	SourceReferences sourceReferences `json:"sourceReferences,omitempty"`
 }
 
-// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+// MarshalLogObject implements zapcore.ObjectMarshaller interface. Unset fields
+// are omitted, unless the `KeepZeroFields` core option is used.
 func (context reportContext) MarshalLogObject(enc zapcore.ObjectEncoder) error {
-	enc.AddObject("reportLocation", context.ReportLocation)
+	return context.marshalLogObject(enc, true)
+}
+
+func (context reportContext) marshalLogObject(enc zapcore.ObjectEncoder, omitZero bool) error {
+	if !omitZero || context.ReportLocation != (reportLocation{}) {
+		if err := enc.AddObject("reportLocation", zeroFieldsObject{context.ReportLocation, omitZero}); err != nil {
+			return err
+		}
+	}
+
+	if len(context.SourceReferences) > 0 {
+		return enc.AddArray("sourceReferences", context.SourceReferences)
+	}
 
 	return nil
 }
@@ -26,3 +26,36 @@ func TestNewReportContext(t *testing.T) {
 	assert.Equal(t, "23", got.ReportLocation.Line)
 	assert.Contains(t, got.ReportLocation.Function, "zapdriver.TestNewReportContext")
 }
+
+func TestReportContext_MarshalLogObject(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		context *reportContext
+		want    string
+	}{
+		"location": {
+			&reportContext{ReportLocation: reportLocation{File: "main.go", Line: "12", Function: "main.main"}},
+			`{"context":{"reportLocation":{"filePath":"main.go","lineNumber":"12","functionName":"main.main"}}}`,
+		},
+		"partial location": {
+			&reportContext{ReportLocation: reportLocation{File: "main.go"}},
+			`{"context":{"reportLocation":{"filePath":"main.go"}}}`,
+		},
+		"empty": {&reportContext{}, `{"context":{}}`},
+	}
+
+	for name, tt := range tests {
+		assert.Equal(t, tt.want, encodeJSON(t, contextKey, tt.context), name)
+	}
+}
+
+func TestErrorReportFromCaller(t *testing.T) {
+	t.Parallel()
+
+	want, got := newReportContext(runtime.Caller(0)), ErrorReportFromCaller(0).Interface.(*reportContext)
+
+	assert.Contains(t, got.ReportLocation.File, "zapdriver/report_test.go")
+	assert.Equal(t, want.ReportLocation.Line, got.ReportLocation.Line)
+	assert.Contains(t, got.ReportLocation.Function, "zapdriver.TestErrorReportFromCaller")
+}
@@ -0,0 +1,132 @@
+package zapdriver
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const suppressedCountKey = "suppressed_count"
+
+// reportLimiter limits the number of identical errors that are reported to
+// Error Reporting. Errors are identical if they have the same message, report
+// location and error type.
+type reportLimiter struct {
+	limit  uint64
+	window time.Duration
+
+	mutex   sync.Mutex
+	resetAt time.Time
+	reports map[string]*reportCount
+
+	// now is used to get the current time, and can be replaced in tests.
+	now func() time.Time
+}
+
+// reportCount is the number of times an error occurred within a window.
+type reportCount struct {
+	Message   string
+	Location  reportLocation
+	ErrorType string
+	Count     uint64
+}
+
+func newReportLimiter(limit int, window time.Duration) *reportLimiter {
+	return &reportLimiter{
+		limit:   uint64(limit),
+		window:  window,
+		reports: map[string]*reportCount{},
+		now:     time.Now,
+	}
+}
+
+// allow counts an occurrence of the error, and returns the number of times it
+// occurred in the current window. When a new window started, it also returns
+// the errors that were suppressed during the previous one.
+func (l *reportLimiter) allow(msg string, location reportLocation, errorType string) (uint64, bool, reportSummary) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var summary reportSummary
+	if now := l.now(); !now.Before(l.resetAt) {
+		summary = l.reset(now)
+	}
+
+	key := fmt.Sprintf("%s\x00%s:%s:%s\x00%s", msg, location.File, location.Line, location.Function, errorType)
+
+	r, ok := l.reports[key]
+	if !ok {
+		r = &reportCount{Message: msg, Location: location, ErrorType: errorType}
+		l.reports[key] = r
+	}
+	r.Count++
+
+	return r.Count, r.Count <= l.limit, summary
+}
+
+// flush ends the current window, and returns the errors that were suppressed
+// during it.
+func (l *reportLimiter) flush() reportSummary {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.reset(l.now())
+}
+
+// reset starts a new window at now, and returns the errors that were suppressed
+// during the previous one, most frequent first. The mutex must be held.
+func (l *reportLimiter) reset(now time.Time) reportSummary {
+	var summary reportSummary
+	for _, r := range l.reports {
+		if r.Count > l.limit {
+			summary = append(summary, r)
+		}
+	}
+
+	sort.Slice(summary, func(i, j int) bool {
+		return summary[i].Count > summary[j].Count
+	})
+
+	l.reports = map[string]*reportCount{}
+	l.resetAt = now.Add(l.window)
+
+	return summary
+}
+
+// reportSummary is the list of errors that were suppressed during a window.
+type reportSummary []*reportCount
+
+// MarshalLogArray implements zapcore.ArrayMarshaler.
+func (s reportSummary) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, r := range s {
+		if err := enc.AppendObject(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler.
+func (r *reportCount) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("message", r.Message)
+	enc.AddString("errorType", r.ErrorType)
+	enc.AddUint64("count", r.Count)
+
+	return enc.AddObject("reportLocation", r.Location)
+}
+
+// errorType returns the type of the first error field, or an empty string if
+// there is none.
+func errorType(fields []zapcore.Field) string {
+	for i := range fields {
+		if fields[i].Type == zapcore.ErrorType && fields[i].Interface != nil {
+			return fmt.Sprintf("%T", fields[i].Interface)
+		}
+	}
+
+	return ""
+}
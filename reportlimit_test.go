@@ -0,0 +1,135 @@
+package zapdriver
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type testError struct{}
+
+func (*testError) Error() string { return "test error" }
+
+func TestReportLimiter(t *testing.T) {
+	now := time.Date(2019, 6, 1, 10, 0, 0, 0, time.UTC)
+	l := newReportLimiter(2, time.Minute)
+	l.now = func() time.Time { return now }
+
+	location := reportLocation{File: "main.go", Line: "12", Function: "main.main"}
+	for i := uint64(1); i <= 4; i++ {
+		n, allowed, summary := l.allow("oops", location, "*errors.errorString")
+
+		assert.Equal(t, i, n)
+		assert.Equal(t, i <= 2, allowed)
+		assert.Nil(t, summary)
+	}
+
+	_, allowed, _ := l.allow("oops", location, "*os.PathError")
+	assert.True(t, allowed)
+	_, allowed, _ = l.allow("oops", reportLocation{File: "main.go", Line: "13"}, "*errors.errorString")
+	assert.True(t, allowed)
+	_, allowed, _ = l.allow("other", location, "*errors.errorString")
+	assert.True(t, allowed)
+
+	now = now.Add(time.Minute)
+	n, allowed, summary := l.allow("oops", location, "*errors.errorString")
+
+	assert.Equal(t, uint64(1), n)
+	assert.True(t, allowed)
+	require.Len(t, summary, 1)
+	assert.Equal(t, &reportCount{
+		Message:   "oops",
+		Location:  location,
+		ErrorType: "*errors.errorString",
+		Count:     4,
+	}, summary[0])
+}
+
+func TestErrorType(t *testing.T) {
+	assert.Equal(t, "", errorType([]zapcore.Field{zap.String("foo", "bar")}))
+	assert.Equal(t, "*errors.errorString", errorType([]zapcore.Field{zap.Error(errors.New("oops"))}))
+	assert.Equal(t, "*zapdriver.testError", errorType([]zapcore.Field{zap.String("foo", "bar"), zap.Error(&testError{})}))
+}
+
+func TestWriteErrorReportLimit(t *testing.T) {
+	now := time.Date(2019, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, zap.AddCaller(), WrapCore(
+		ReportAllErrors(true),
+		ServiceName("service"),
+		ErrorReportLimit(2, time.Minute),
+		func(c *core) { c.config.ReportLimiter.now = func() time.Time { return now } },
+	))
+
+	for i := 0; i < 5; i++ {
+		logger.Error("request failed", zap.Error(errors.New("timeout")))
+	}
+	logger.Warn("not reported")
+
+	require.Equal(t, 6, logs.Len())
+	for i, entry := range logs.All()[:5] {
+		context := entry.ContextMap()
+
+		if i < 2 {
+			assert.Contains(t, context, contextKey)
+			assert.NotContains(t, context, suppressedCountKey)
+			continue
+		}
+
+		assert.NotContains(t, context, contextKey)
+		assert.Equal(t, uint64(i-1), context[suppressedCountKey])
+	}
+	assert.NotContains(t, logs.All()[5].ContextMap(), suppressedCountKey)
+
+	now = now.Add(time.Minute)
+	logger.Error("request failed", zap.Error(errors.New("timeout")))
+
+	require.Equal(t, 8, logs.Len())
+	summary := logs.All()[6]
+	assert.Equal(t, zapcore.WarnLevel, summary.Level)
+	assert.Equal(t, "zapdriver: error reports suppressed", summary.Message)
+
+	suppressed := summary.ContextMap()["suppressed"].([]interface{})
+	require.Len(t, suppressed, 1)
+	assert.Equal(t, "request failed", suppressed[0].(map[string]interface{})["message"])
+	assert.Equal(t, "*errors.errorString", suppressed[0].(map[string]interface{})["errorType"])
+	assert.Equal(t, uint64(5), suppressed[0].(map[string]interface{})["count"])
+
+	assert.Contains(t, logs.All()[7].ContextMap(), contextKey)
+}
+
+func TestSyncSuppressedErrors(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, zap.AddCaller(), WrapCore(
+		ReportAllErrors(true),
+		ErrorReportLimit(1, time.Hour),
+	))
+
+	for i := 0; i < 3; i++ {
+		logger.Error("request failed")
+	}
+	require.Equal(t, 3, logs.Len())
+
+	// No more errors are reported in this window, so the aggregate is only
+	// written when syncing.
+	require.NoError(t, logger.Sync())
+	require.Equal(t, 4, logs.Len())
+	assert.Equal(t, "zapdriver: error reports suppressed", logs.All()[3].Message)
+
+	suppressed := logs.All()[3].ContextMap()["suppressed"].([]interface{})
+	require.Len(t, suppressed, 1)
+	assert.Equal(t, uint64(3), suppressed[0].(map[string]interface{})["count"])
+
+	// Syncing ended the window, so the error is reported again.
+	logger.Error("request failed")
+	require.NoError(t, logger.Sync())
+	require.Equal(t, 5, logs.Len())
+	assert.Contains(t, logs.All()[4].ContextMap(), contextKey)
+}
@@ -0,0 +1,245 @@
+package zapdriver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMetadataURL   = "http://metadata.google.internal/computeMetadata/v1/"
+	defaultNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// Resource is the monitored resource that produces log entries, such as a GKE
+// container, a Cloud Run revision or a GCE instance.
+//
+// see: https://cloud.google.com/logging/docs/api/v2/resource-list
+type Resource struct {
+	// The monitored resource type. Examples: "k8s_container",
+	// "cloud_run_revision", "gce_instance".
+	Type string `json:"type"`
+
+	// Values for the labels of the monitored resource type. Examples:
+	// "cluster_name", "namespace_name", "revision_name", "zone".
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// resourceDetector is used to configure the detection of a Resource.
+type resourceDetector struct {
+	// MetadataURL is the base URL of the GCE metadata server.
+	MetadataURL string
+
+	// Getenv is used to look up environment variables.
+	Getenv func(string) string
+
+	// NamespaceFile is the file containing the Kubernetes namespace of the pod.
+	NamespaceFile string
+
+	// Client is used to query the metadata server.
+	Client *http.Client
+
+	// unavailable is set once the metadata server could not be reached, to
+	// prevent waiting for it again.
+	unavailable bool
+}
+
+// ResourceMetadataURL sets the base URL of the metadata server used to detect
+// the resource. It defaults to the GCE metadata server, or the host set in the
+// `GCE_METADATA_HOST` environment variable.
+func ResourceMetadataURL(url string) func(*resourceDetector) {
+	return func(d *resourceDetector) {
+		d.MetadataURL = url
+	}
+}
+
+// ResourceGetenv sets the function used to look up environment variables while
+// detecting the resource. It defaults to `os.Getenv`.
+func ResourceGetenv(getenv func(string) string) func(*resourceDetector) {
+	return func(d *resourceDetector) {
+		d.Getenv = getenv
+	}
+}
+
+// ResourceNamespaceFile sets the file that contains the Kubernetes namespace of
+// the pod. It defaults to the namespace file of the pod's service account.
+func ResourceNamespaceFile(path string) func(*resourceDetector) {
+	return func(d *resourceDetector) {
+		d.NamespaceFile = path
+	}
+}
+
+// DetectResource detects the environment the application runs in, based on
+// environment variables, files and the metadata server. It supports Cloud
+// Functions, Cloud Run, App Engine, GKE and GCE, and falls back to the "global"
+// resource type.
+func DetectResource(options ...func(*resourceDetector)) *Resource {
+	d := &resourceDetector{
+		MetadataURL:   defaultMetadataURL,
+		Getenv:        os.Getenv,
+		NamespaceFile: defaultNamespaceFile,
+		Client:        &http.Client{Timeout: time.Second},
+	}
+	if host := os.Getenv("GCE_METADATA_HOST"); host != "" {
+		d.MetadataURL = "http://" + host + "/computeMetadata/v1/"
+	}
+	for _, option := range options {
+		option(d)
+	}
+
+	return d.detect()
+}
+
+func (d *resourceDetector) detect() *Resource {
+	switch {
+	case d.Getenv("FUNCTION_TARGET") != "" && d.Getenv("K_SERVICE") != "":
+		return d.resource("cloud_function", map[string]string{
+			"function_name": d.Getenv("K_SERVICE"),
+			"region":        d.region(),
+		})
+
+	case d.Getenv("FUNCTION_NAME") != "":
+		region := d.Getenv("FUNCTION_REGION")
+		if region == "" {
+			region = d.region()
+		}
+
+		return d.resource("cloud_function", map[string]string{
+			"function_name": d.Getenv("FUNCTION_NAME"),
+			"region":        region,
+		})
+
+	case d.Getenv("K_SERVICE") != "":
+		return d.resource("cloud_run_revision", map[string]string{
+			"service_name":       d.Getenv("K_SERVICE"),
+			"revision_name":      d.Getenv("K_REVISION"),
+			"configuration_name": d.Getenv("K_CONFIGURATION"),
+			"location":           d.region(),
+		})
+
+	case d.Getenv("GAE_SERVICE") != "":
+		return d.resource("gae_app", map[string]string{
+			"module_id":  d.Getenv("GAE_SERVICE"),
+			"version_id": d.Getenv("GAE_VERSION"),
+			"zone":       d.zone(),
+		})
+
+	case d.Getenv("KUBERNETES_SERVICE_HOST") != "":
+		location := d.metadata("instance/attributes/cluster-location")
+		if location == "" {
+			location = d.zone()
+		}
+
+		return d.resource("k8s_container", map[string]string{
+			"location":       location,
+			"cluster_name":   d.metadata("instance/attributes/cluster-name"),
+			"namespace_name": d.namespace(),
+			"pod_name":       d.firstEnv("POD_NAME", "HOSTNAME"),
+			"container_name": d.Getenv("CONTAINER_NAME"),
+		})
+	}
+
+	if id := d.metadata("instance/id"); id != "" {
+		return d.resource("gce_instance", map[string]string{
+			"instance_id": id,
+			"zone":        d.zone(),
+		})
+	}
+
+	return d.resource("global", map[string]string{})
+}
+
+// resource returns a new Resource, with the project ID added to the non-empty
+// labels.
+func (d *resourceDetector) resource(typ string, labels map[string]string) *Resource {
+	labels["project_id"] = d.projectID()
+
+	for k, v := range labels {
+		if v == "" {
+			delete(labels, k)
+		}
+	}
+
+	return &Resource{Type: typ, Labels: labels}
+}
+
+func (d *resourceDetector) projectID() string {
+	if id := d.firstEnv("GOOGLE_CLOUD_PROJECT", "GCP_PROJECT", "GCLOUD_PROJECT"); id != "" {
+		return id
+	}
+
+	return d.metadata("project/project-id")
+}
+
+// region returns the region of the instance. The metadata server returns it as
+// "projects/<number>/regions/<region>".
+func (d *resourceDetector) region() string {
+	return lastPathSegment(d.metadata("instance/region"))
+}
+
+// zone returns the zone of the instance. The metadata server returns it as
+// "projects/<number>/zones/<zone>".
+func (d *resourceDetector) zone() string {
+	return lastPathSegment(d.metadata("instance/zone"))
+}
+
+func (d *resourceDetector) namespace() string {
+	if ns := d.Getenv("POD_NAMESPACE"); ns != "" {
+		return ns
+	}
+
+	b, err := ioutil.ReadFile(d.NamespaceFile)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(b))
+}
+
+func (d *resourceDetector) firstEnv(keys ...string) string {
+	for _, key := range keys {
+		if v := d.Getenv(key); v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// metadata returns the value at path on the metadata server, or an empty string
+// if the metadata server is unavailable.
+func (d *resourceDetector) metadata(path string) string {
+	if d.unavailable {
+		return ""
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(d.MetadataURL, "/")+"/"+path, nil)
+	if err != nil {
+		return ""
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	res, err := d.Client.Do(req)
+	if err != nil {
+		d.unavailable = true
+		return ""
+	}
+	defer res.Body.Close() // nolint: errcheck
+
+	if res.StatusCode != http.StatusOK {
+		return ""
+	}
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(b))
+}
+
+func lastPathSegment(s string) string {
+	return s[strings.LastIndex(s, "/")+1:]
+}
@@ -0,0 +1,164 @@
+package zapdriver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newMetadataServer(values map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		v, ok := values[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte(v))
+	}))
+}
+
+func getenv(env map[string]string) func(string) string {
+	return func(key string) string { return env[key] }
+}
+
+func TestDetectResource(t *testing.T) {
+	t.Parallel()
+
+	metadata := newMetadataServer(map[string]string{
+		"/computeMetadata/v1/project/project-id":                   "my-project",
+		"/computeMetadata/v1/instance/id":                          "1234",
+		"/computeMetadata/v1/instance/zone":                        "projects/1/zones/europe-west4-a",
+		"/computeMetadata/v1/instance/region":                      "projects/1/regions/europe-west4",
+		"/computeMetadata/v1/instance/attributes/cluster-name":     "my-cluster",
+		"/computeMetadata/v1/instance/attributes/cluster-location": "europe-west4",
+	})
+	defer metadata.Close()
+
+	dir, err := ioutil.TempDir("", "zapdriver")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	namespaceFile := filepath.Join(dir, "namespace")
+	require.NoError(t, ioutil.WriteFile(namespaceFile, []byte("my-namespace\n"), 0600))
+
+	var tests = map[string]struct {
+		env  map[string]string
+		want *Resource
+	}{
+		"gce": {
+			map[string]string{},
+			&Resource{Type: "gce_instance", Labels: map[string]string{
+				"project_id":  "my-project",
+				"instance_id": "1234",
+				"zone":        "europe-west4-a",
+			}},
+		},
+
+		"gke": {
+			map[string]string{"KUBERNETES_SERVICE_HOST": "10.0.0.1", "HOSTNAME": "my-pod", "CONTAINER_NAME": "app"},
+			&Resource{Type: "k8s_container", Labels: map[string]string{
+				"project_id":     "my-project",
+				"location":       "europe-west4",
+				"cluster_name":   "my-cluster",
+				"namespace_name": "my-namespace",
+				"pod_name":       "my-pod",
+				"container_name": "app",
+			}},
+		},
+
+		"cloud run": {
+			map[string]string{"K_SERVICE": "my-service", "K_REVISION": "my-service-001", "K_CONFIGURATION": "my-service"},
+			&Resource{Type: "cloud_run_revision", Labels: map[string]string{
+				"project_id":         "my-project",
+				"service_name":       "my-service",
+				"revision_name":      "my-service-001",
+				"configuration_name": "my-service",
+				"location":           "europe-west4",
+			}},
+		},
+
+		"cloud functions": {
+			map[string]string{"K_SERVICE": "my-function", "FUNCTION_TARGET": "Handle"},
+			&Resource{Type: "cloud_function", Labels: map[string]string{
+				"project_id":    "my-project",
+				"function_name": "my-function",
+				"region":        "europe-west4",
+			}},
+		},
+
+		"cloud functions legacy": {
+			map[string]string{"FUNCTION_NAME": "my-function", "FUNCTION_REGION": "us-central1", "GCP_PROJECT": "other-project"},
+			&Resource{Type: "cloud_function", Labels: map[string]string{
+				"project_id":    "other-project",
+				"function_name": "my-function",
+				"region":        "us-central1",
+			}},
+		},
+
+		"app engine": {
+			map[string]string{"GAE_SERVICE": "default", "GAE_VERSION": "v1", "GOOGLE_CLOUD_PROJECT": "other-project"},
+			&Resource{Type: "gae_app", Labels: map[string]string{
+				"project_id": "other-project",
+				"module_id":  "default",
+				"version_id": "v1",
+				"zone":       "europe-west4-a",
+			}},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := DetectResource(
+				ResourceMetadataURL(metadata.URL+"/computeMetadata/v1/"),
+				ResourceGetenv(getenv(tt.env)),
+				ResourceNamespaceFile(namespaceFile),
+			)
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDetectResource_Global(t *testing.T) {
+	t.Parallel()
+
+	metadata := newMetadataServer(map[string]string{})
+	metadata.Close()
+
+	got := DetectResource(
+		ResourceMetadataURL(metadata.URL),
+		ResourceGetenv(getenv(map[string]string{"GOOGLE_CLOUD_PROJECT": "my-project"})),
+	)
+
+	assert.Equal(t, &Resource{Type: "global", Labels: map[string]string{"project_id": "my-project"}}, got)
+}
+
+func TestResourceLabels(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	resource := &Resource{Type: "k8s_container", Labels: map[string]string{"pod_name": "my-pod"}}
+
+	logger := zap.New(debugcore, WrapCore(ResourceLabels(resource)))
+	logger.Info("hello world", Label("pod_name", "other"))
+
+	labels := logs.All()[0].ContextMap()[labelsKey].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"resource.type":     "k8s_container",
+		"resource.pod_name": "my-pod",
+		"pod_name":          "other",
+	}, labels)
+}
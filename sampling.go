@@ -0,0 +1,268 @@
+package zapdriver
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// SamplingRule defines how a Sampler samples the entries that match it.
+//
+// An entry matches a rule if its level is one of the rule's levels, and it has
+// the rule's label. Matching entries are kept if the rule has Keep set, kept at
+// random if the rule has a Rate, and otherwise counted per tick: the first
+// Initial entries are kept, after which every Thereafter-th entry is kept.
+type SamplingRule struct {
+	// Name identifies the rule in the summary entries.
+	Name string `json:"name"`
+
+	// Levels the rule applies to. If empty, the rule applies to all levels.
+	Levels []zapcore.Level `json:"levels,omitempty"`
+
+	// Label the entries must have for the rule to apply. Entries are counted
+	// per label value instead of per message when set.
+	Label string `json:"label,omitempty"`
+
+	// Keep keeps all matching entries.
+	Keep bool `json:"keep,omitempty"`
+
+	// Rate is the fraction of matching entries to keep, between 0 and 1.
+	Rate float64 `json:"rate,omitempty"`
+
+	// Initial is the number of entries to keep each tick, per message (or per
+	// label value).
+	Initial int `json:"initial,omitempty"`
+
+	// Thereafter keeps every Thereafter-th entry after the Initial ones. If zero,
+	// all entries after the Initial ones are dropped.
+	Thereafter int `json:"thereafter,omitempty"`
+}
+
+// KeepLevel returns a SamplingRule that never drops entries with level `min`
+// or above.
+func KeepLevel(min zapcore.Level) SamplingRule {
+	rule := SamplingRule{Name: "keep-" + min.String(), Keep: true}
+	for l := min; l <= zapcore.FatalLevel; l++ {
+		rule.Levels = append(rule.Levels, l)
+	}
+
+	return rule
+}
+
+// SampleLevelRate returns a SamplingRule that keeps the fraction `rate` of the
+// entries with the given level, at random.
+func SampleLevelRate(level zapcore.Level, rate float64) SamplingRule {
+	return SamplingRule{
+		Name:   "rate-" + level.String(),
+		Levels: []zapcore.Level{level},
+		Rate:   rate,
+	}
+}
+
+// SampleByLabel returns a SamplingRule that keeps the first `initial` entries
+// with the same value for the label `key` each tick, and every `thereafter`th
+// entry after that.
+func SampleByLabel(key string, initial, thereafter int) SamplingRule {
+	return SamplingRule{
+		Name:       "label-" + key,
+		Label:      key,
+		Initial:    initial,
+		Thereafter: thereafter,
+	}
+}
+
+func (r *SamplingRule) matches(level zapcore.Level, labels map[string]string) bool {
+	if r.Label != "" {
+		if _, ok := labels[r.Label]; !ok {
+			return false
+		}
+	}
+
+	if len(r.Levels) == 0 {
+		return true
+	}
+
+	for _, l := range r.Levels {
+		if l == level {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sampler samples log entries to cap the CPU and I/O load of logging, while
+// keeping a representative subset of the entries.
+//
+// Within each tick, the first N entries with a given level and message are
+// logged, after which every Mth entry is logged and the rest is dropped. Rules
+// can be added to sample some entries differently. Entries that belong to a
+// sampled trace (with `trace_sampled` set to true) are never dropped, to keep
+// the trace timelines complete.
+//
+// The number of dropped entries is logged lazily: in a summary entry written
+// before the first entry of the next tick, or when the logger is synced. Call
+// `Sync` before exiting the application, so the last summary is not lost.
+//
+// Unlike the sampler of zap, a Sampler is applied by the zapdriver core, after
+// the fields of the entry are known. Use the `Sampling()` core option to enable
+// it.
+type Sampler struct {
+	tick  time.Duration
+	rules []SamplingRule
+
+	mutex      sync.Mutex
+	resetAt    time.Time
+	counts     map[samplerKey]uint64
+	dropped    map[string]uint64
+	suppressed samplingSummary
+
+	// now and random are used to get the current time and random numbers, and
+	// can be replaced in tests.
+	now    func() time.Time
+	random func() float64
+}
+
+type samplerKey struct {
+	rule  int
+	level zapcore.Level
+	value string
+}
+
+// NewSampler returns a Sampler that logs the first `first` entries with a given
+// level and message each `tick`, and every `thereafter`th entry after that. If
+// `thereafter` is zero, all entries after the first ones are dropped.
+//
+// The rules are applied to the entries that match them instead, in order. The
+// first matching rule is used.
+func NewSampler(tick time.Duration, first, thereafter int, rules ...SamplingRule) *Sampler {
+	rules = append(rules, SamplingRule{
+		Name:       "default",
+		Initial:    first,
+		Thereafter: thereafter,
+	})
+
+	return &Sampler{
+		tick:       tick,
+		rules:      rules,
+		counts:     map[samplerKey]uint64{},
+		dropped:    map[string]uint64{},
+		suppressed: samplingSummary{},
+		now:        time.Now,
+		random:     rand.Float64,
+	}
+}
+
+// Dropped returns the number of entries dropped so far, by their Stackdriver
+// severity.
+func (s *Sampler) Dropped() map[string]uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	out := make(map[string]uint64, len(s.dropped))
+	for severity, n := range s.dropped {
+		out[severity] = n
+	}
+
+	return out
+}
+
+// sample reports whether the entry should be logged. When a new tick started
+// and entries were dropped during the previous one, it also returns the number
+// of dropped entries per rule, to be logged as a summary.
+func (s *Sampler) sample(ent zapcore.Entry, labels map[string]string) (bool, samplingSummary) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var summary samplingSummary
+	if now := s.now(); !now.Before(s.resetAt) {
+		if len(s.suppressed) > 0 {
+			summary, s.suppressed = s.suppressed, samplingSummary{}
+		}
+
+		s.counts = map[samplerKey]uint64{}
+		s.resetAt = now.Add(s.tick)
+	}
+
+	for i := range s.rules {
+		rule := &s.rules[i]
+		if !rule.matches(ent.Level, labels) {
+			continue
+		}
+
+		if s.keep(i, rule, ent, labels) {
+			return true, summary
+		}
+
+		s.dropped[logLevelSeverity[ent.Level]]++
+		s.suppressed[rule.Name]++
+		return false, summary
+	}
+
+	return true, summary
+}
+
+// flush returns the number of dropped entries per rule since the previous
+// summary, and resets it.
+func (s *Sampler) flush() samplingSummary {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	summary := s.suppressed
+	s.suppressed = samplingSummary{}
+
+	return summary
+}
+
+func (s *Sampler) keep(i int, rule *SamplingRule, ent zapcore.Entry, labels map[string]string) bool {
+	switch {
+	case rule.Keep:
+		return true
+	case rule.Rate > 0:
+		return s.random() < rule.Rate
+	}
+
+	key := samplerKey{rule: i, level: ent.Level, value: ent.Message}
+	if rule.Label != "" {
+		key = samplerKey{rule: i, value: labels[rule.Label]}
+	}
+
+	s.counts[key]++
+	n, first, thereafter := s.counts[key], uint64(rule.Initial), uint64(rule.Thereafter)
+
+	return n <= first || (thereafter > 0 && (n-first)%thereafter == 0)
+}
+
+// samplingSummary is the number of dropped entries, by the name of the rule
+// that dropped them.
+type samplingSummary map[string]uint64
+
+// MarshalLogObject implements zapcore.ObjectMarshaler.
+func (s samplingSummary) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		enc.AddUint64(name, s[name])
+	}
+
+	return nil
+}
+
+// isTraceSampled reports whether fields contain a `trace_sampled` field set to
+// true.
+func isTraceSampled(fields []zapcore.Field) bool {
+	for i := range fields {
+		if fields[i].Key == traceSampledKey && fields[i].Type == zapcore.BoolType {
+			return fields[i].Integer == 1
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,185 @@
+package zapdriver
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// keep reports whether the sampler keeps the entry, ignoring the summary.
+func keep(s *Sampler, ent zapcore.Entry, labels map[string]string) bool {
+	ok, _ := s.sample(ent, labels)
+	return ok
+}
+
+func TestSampler(t *testing.T) {
+	now := time.Date(2019, 6, 1, 10, 0, 0, 0, time.UTC)
+	s := NewSampler(time.Second, 2, 3)
+	s.now = func() time.Time { return now }
+
+	var kept []int
+	for i := 1; i <= 8; i++ {
+		if keep(s, zapcore.Entry{Level: zapcore.InfoLevel, Message: "hello"}, nil) {
+			kept = append(kept, i)
+		}
+	}
+
+	assert.Equal(t, []int{1, 2, 5, 8}, kept)
+	assert.True(t, keep(s, zapcore.Entry{Level: zapcore.InfoLevel, Message: "other"}, nil))
+	assert.True(t, keep(s, zapcore.Entry{Level: zapcore.WarnLevel, Message: "hello"}, nil))
+	assert.Equal(t, map[string]uint64{"INFO": 4}, s.Dropped())
+
+	now = now.Add(time.Second)
+	assert.True(t, keep(s, zapcore.Entry{Level: zapcore.InfoLevel, Message: "hello"}, nil))
+}
+
+func TestSampler_ThereafterZero(t *testing.T) {
+	s := NewSampler(time.Minute, 1, 0)
+
+	assert.True(t, keep(s, zapcore.Entry{Level: zapcore.ErrorLevel, Message: "hello"}, nil))
+	assert.False(t, keep(s, zapcore.Entry{Level: zapcore.ErrorLevel, Message: "hello"}, nil))
+	assert.False(t, keep(s, zapcore.Entry{Level: zapcore.ErrorLevel, Message: "hello"}, nil))
+	assert.Equal(t, map[string]uint64{"ERROR": 2}, s.Dropped())
+}
+
+func TestWriteSampling(t *testing.T) {
+	sampler := NewSampler(time.Minute, 1, 0)
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(Sampling(sampler)))
+
+	for i := 0; i < 3; i++ {
+		logger.Info("hello")
+		logger.Info("traced", TraceContext("abc", "def", true, "p")...)
+		logger.Info("not sampled", TraceContext("abc", "def", false, "p")...)
+	}
+
+	traced := logger.With(TraceContext("abc", "def", true, "p")...)
+	for i := 0; i < 3; i++ {
+		traced.Info("hello")
+		traced.With(zap.String("foo", "bar")).Info("hello")
+	}
+
+	assert.Equal(t, 7, logs.FilterMessage("hello").Len())
+	assert.Equal(t, 6, logs.FilterMessage("hello").FilterField(zap.Bool(traceSampledKey, true)).Len())
+	assert.Equal(t, 3, logs.FilterMessage("traced").Len())
+	assert.Equal(t, 1, logs.FilterMessage("not sampled").Len())
+	assert.Equal(t, map[string]uint64{"INFO": 4}, sampler.Dropped())
+}
+
+func TestSampler_Rules(t *testing.T) {
+	s := NewSampler(time.Minute, 1, 0,
+		KeepLevel(zapcore.WarnLevel),
+		SampleLevelRate(zapcore.DebugLevel, 0.01),
+		SampleByLabel("tenant", 2, 0),
+	)
+
+	random := 0.5
+	s.random = func() float64 { return random }
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, keep(s, zapcore.Entry{Level: zapcore.WarnLevel, Message: "warn"}, nil))
+		assert.True(t, keep(s, zapcore.Entry{Level: zapcore.FatalLevel, Message: "fatal"}, nil))
+	}
+
+	assert.False(t, keep(s, zapcore.Entry{Level: zapcore.DebugLevel, Message: "debug"}, nil))
+	random = 0.001
+	assert.True(t, keep(s, zapcore.Entry{Level: zapcore.DebugLevel, Message: "debug"}, nil))
+
+	acme := map[string]string{"tenant": "acme"}
+	assert.True(t, keep(s, zapcore.Entry{Level: zapcore.InfoLevel, Message: "one"}, acme))
+	assert.True(t, keep(s, zapcore.Entry{Level: zapcore.InfoLevel, Message: "two"}, acme))
+	assert.False(t, keep(s, zapcore.Entry{Level: zapcore.InfoLevel, Message: "three"}, acme))
+	assert.True(t, keep(s, zapcore.Entry{Level: zapcore.InfoLevel, Message: "three"}, map[string]string{"tenant": "other"}))
+
+	assert.True(t, keep(s, zapcore.Entry{Level: zapcore.InfoLevel, Message: "three"}, nil))
+	assert.False(t, keep(s, zapcore.Entry{Level: zapcore.InfoLevel, Message: "three"}, nil))
+
+	assert.Equal(t, map[string]uint64{"DEBUG": 1, "INFO": 2}, s.Dropped())
+}
+
+func TestSampler_Summary(t *testing.T) {
+	now := time.Date(2019, 6, 1, 10, 0, 0, 0, time.UTC)
+	s := NewSampler(time.Second, 1, 0, SampleByLabel("tenant", 0, 0))
+	s.now = func() time.Time { return now }
+
+	acme := map[string]string{"tenant": "acme"}
+	for i := 0; i < 3; i++ {
+		_, summary := s.sample(zapcore.Entry{Message: "hello"}, nil)
+		assert.Nil(t, summary)
+		_, summary = s.sample(zapcore.Entry{Message: "hello"}, acme)
+		assert.Nil(t, summary)
+	}
+
+	now = now.Add(time.Second)
+	_, summary := s.sample(zapcore.Entry{Message: "hello"}, nil)
+	assert.Equal(t, samplingSummary{"default": 2, "label-tenant": 3}, summary)
+
+	now = now.Add(time.Second)
+	_, summary = s.sample(zapcore.Entry{Message: "hello"}, nil)
+	assert.Nil(t, summary)
+}
+
+func TestSamplingRule_JSON(t *testing.T) {
+	rule := KeepLevel(zapcore.ErrorLevel)
+
+	b, err := json.Marshal(rule)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"keep-error","levels":["error","dpanic","panic","fatal"],"keep":true}`, string(b))
+
+	var out SamplingRule
+	require.NoError(t, json.Unmarshal(b, &out))
+	assert.Equal(t, rule, out)
+}
+
+func TestWriteSamplingSummary(t *testing.T) {
+	now := time.Date(2019, 6, 1, 10, 0, 0, 0, time.UTC)
+	sampler := NewSampler(time.Second, 1, 0, SampleByLabel("tenant", 1, 0))
+	sampler.now = func() time.Time { return now }
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(Sampling(sampler)))
+
+	for i := 0; i < 3; i++ {
+		logger.Info("hello")
+		logger.Info("tenant", Label("tenant", "acme"))
+	}
+	require.Equal(t, 2, logs.Len())
+
+	now = now.Add(time.Second)
+	logger.Info("hello")
+
+	require.Equal(t, 4, logs.Len())
+	summary := logs.All()[2]
+	assert.Equal(t, "zapdriver: entries suppressed by sampling", summary.Message)
+	assert.Equal(t, map[string]interface{}{"default": uint64(2), "label-tenant": uint64(2)}, summary.ContextMap()["suppressed"])
+	assert.Equal(t, "hello", logs.All()[3].Message)
+}
+
+func TestSyncSamplingSummary(t *testing.T) {
+	sampler := NewSampler(time.Hour, 1, 0)
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(Sampling(sampler)))
+
+	for i := 0; i < 3; i++ {
+		logger.Info("hello")
+	}
+	require.Equal(t, 1, logs.Len())
+
+	// No more entries are logged in this tick, so the summary is only written
+	// when syncing.
+	require.NoError(t, logger.Sync())
+	require.Equal(t, 2, logs.Len())
+	assert.Equal(t, "zapdriver: entries suppressed by sampling", logs.All()[1].Message)
+	assert.Equal(t, map[string]interface{}{"default": uint64(2)}, logs.All()[1].ContextMap()["suppressed"])
+
+	require.NoError(t, logger.Sync())
+	assert.Equal(t, 2, logs.Len(), "the summary is only written once")
+}
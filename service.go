@@ -16,15 +16,28 @@ func ServiceContext(name string) zap.Field {
 	return zap.Object(serviceContextKey, newServiceContext(name))
 }
 
+// ServiceContextWithVersion is the same as ServiceContext, but also adds the
+// version of the service, such as a release tag or commit hash.
+func ServiceContextWithVersion(name, version string) zap.Field {
+	context := newServiceContext(name)
+	context.Version = version
+
+	return zap.Object(serviceContextKey, context)
+}
+
 // serviceContext describes a running service that sends errors.
-// Currently it only describes a service name.
+// It describes a service name, and optionally its version.
 type serviceContext struct {
-	Name string `json:"service"`
+	Name    string `json:"service"`
+	Version string `json:"version,omitempty"`
 }
 
 // MarshalLogObject implements zapcore.ObjectMarshaller interface.
 func (service_context serviceContext) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 	enc.AddString("service", service_context.Name)
+	if service_context.Version != "" {
+		enc.AddString("version", service_context.Version)
+	}
 
 	return nil
 }
@@ -21,3 +21,12 @@ func TestNewServiceContext(t *testing.T) {
 
 	assert.Equal(t, "test service name", got.Name)
 }
+
+func TestServiceContextWithVersion(t *testing.T) {
+	t.Parallel()
+
+	got := ServiceContextWithVersion("test service name", "v1.2.3").Interface.(*serviceContext)
+
+	assert.Equal(t, "test service name", got.Name)
+	assert.Equal(t, "v1.2.3", got.Version)
+}
@@ -1,8 +1,10 @@
 package zapdriver
 
 import (
+	"path"
 	"runtime"
 	"strconv"
+	"strings"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -17,6 +19,14 @@ func SourceLocation(pc uintptr, file string, line int, ok bool) zap.Field {
 	return zap.Object(sourceKey, newSource(pc, file, line, ok))
 }
 
+// SourceLocationFromCaller adds the source code location of a caller of the
+// function calling it. The argument skip is the number of stack frames to
+// ascend, with 0 identifying the caller of SourceLocationFromCaller. This
+// allows wrapper libraries to report the location of their callers.
+func SourceLocationFromCaller(skip int) zap.Field {
+	return SourceLocation(runtime.Caller(skip + 1))
+}
+
 // source is the source code location information associated with the log entry,
 // if any.
 type source struct {
@@ -37,11 +47,18 @@ type source struct {
 	Function string `json:"function"`
 }
 
-// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+// MarshalLogObject implements zapcore.ObjectMarshaller interface. Unset fields
+// are omitted, unless the `KeepZeroFields` core option is used.
 func (source source) MarshalLogObject(enc zapcore.ObjectEncoder) error {
-	enc.AddString("file", source.File)
-	enc.AddString("line", source.Line)
-	enc.AddString("function", source.Function)
+	return source.marshalLogObject(enc, true)
+}
+
+func (source source) marshalLogObject(enc zapcore.ObjectEncoder, omitZero bool) error {
+	e := zeroFieldsEncoder{enc, omitZero}
+
+	e.AddString("file", source.File)
+	e.AddString("line", source.Line)
+	e.AddString("function", source.Function)
 
 	return nil
 }
@@ -64,3 +81,78 @@ func newSource(pc uintptr, file string, line int, ok bool) *source {
 
 	return source
 }
+
+// sourceFormat controls how the file paths and function names of the source
+// locations added by the zapdriver core are written.
+type sourceFormat struct {
+	// prefix is trimmed from file paths.
+	prefix string
+
+	// module is the path of the main module. Files of the main module are
+	// written relative to the module root when set.
+	module string
+
+	// mainPackage is the import path of the main package, used for functions
+	// in package "main".
+	mainPackage string
+
+	// shortFunctions writes function names without their package directory.
+	shortFunctions bool
+}
+
+// file returns the formatted path of file, which contains function.
+func (f *sourceFormat) file(file, function string) string {
+	if f.module != "" {
+		pkg := functionPackage(function)
+		if pkg == "main" {
+			pkg = f.mainPackage
+		}
+
+		switch {
+		case pkg == f.module:
+			return path.Base(file)
+		case strings.HasPrefix(pkg, f.module+"/"):
+			return strings.TrimPrefix(pkg, f.module+"/") + "/" + path.Base(file)
+		}
+	}
+
+	return strings.TrimPrefix(file, f.prefix)
+}
+
+// function returns the formatted name of function.
+func (f *sourceFormat) function(function string) string {
+	if !f.shortFunctions {
+		return function
+	}
+
+	return function[strings.LastIndex(packageName(function), "/")+1:]
+}
+
+// apply formats the file path and function name of a source location.
+func (f *sourceFormat) apply(file, function *string) {
+	*file = f.file(*file, *function)
+	*function = f.function(*function)
+}
+
+// packageName returns the part of a function name up to the end of its package
+// path, ignoring any type parameters.
+func packageName(function string) string {
+	if i := strings.Index(function, "["); i >= 0 {
+		function = function[:i]
+	}
+
+	return function
+}
+
+// functionPackage returns the import path of the package of a function, such
+// as "github.com/blendle/zapdriver" for "github.com/blendle/zapdriver.(*core).Write".
+func functionPackage(function string) string {
+	name := packageName(function)
+	slash := strings.LastIndex(name, "/")
+
+	if i := strings.Index(name[slash+1:], "."); i >= 0 {
+		return name[:slash+1+i]
+	}
+
+	return name
+}
@@ -26,3 +26,93 @@ func TestNewSource(t *testing.T) {
 	assert.Equal(t, "23", got.Line)
 	assert.Contains(t, got.Function, "zapdriver.TestNewSource")
 }
+
+func TestSource_MarshalLogObject(t *testing.T) {
+	t.Parallel()
+
+	got := encodeJSON(t, sourceKey, &source{File: "main.go", Line: "12"})
+
+	assert.Equal(t, `{"logging.googleapis.com/sourceLocation":{"file":"main.go","line":"12"}}`, got)
+}
+
+func TestSourceFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		format             sourceFormat
+		file, function     string
+		wantFile, wantFunc string
+	}{
+		"unchanged": {
+			sourceFormat{},
+			"/home/ci/shop/internal/orders/orders.go", "github.com/acme/shop/internal/orders.(*Service).Cancel",
+			"/home/ci/shop/internal/orders/orders.go", "github.com/acme/shop/internal/orders.(*Service).Cancel",
+		},
+		"prefix": {
+			sourceFormat{prefix: "/home/ci/shop/"},
+			"/home/ci/shop/internal/orders/orders.go", "github.com/acme/shop/internal/orders.Cancel",
+			"internal/orders/orders.go", "github.com/acme/shop/internal/orders.Cancel",
+		},
+		"module": {
+			sourceFormat{module: "github.com/acme/shop"},
+			"/home/ci/shop/internal/orders/orders.go", "github.com/acme/shop/internal/orders.(*Service).Cancel.func1",
+			"internal/orders/orders.go", "github.com/acme/shop/internal/orders.(*Service).Cancel.func1",
+		},
+		"module root": {
+			sourceFormat{module: "github.com/acme/shop"},
+			"/home/ci/shop/shop.go", "github.com/acme/shop.New",
+			"shop.go", "github.com/acme/shop.New",
+		},
+		"module main package": {
+			sourceFormat{module: "github.com/acme/shop", mainPackage: "github.com/acme/shop/cmd/server"},
+			"/home/ci/shop/cmd/server/main.go", "main.main",
+			"cmd/server/main.go", "main.main",
+		},
+		"other module": {
+			sourceFormat{module: "github.com/acme/shop", prefix: "/home/ci/"},
+			"/home/ci/go/pkg/mod/github.com/acme/shopping@v1.0.0/cart.go", "github.com/acme/shopping.Add",
+			"go/pkg/mod/github.com/acme/shopping@v1.0.0/cart.go", "github.com/acme/shopping.Add",
+		},
+		"short functions": {
+			sourceFormat{shortFunctions: true},
+			"orders.go", "github.com/acme/shop/internal/orders.(*Service).Cancel",
+			"orders.go", "orders.(*Service).Cancel",
+		},
+		"short generic functions": {
+			sourceFormat{shortFunctions: true},
+			"orders.go", "github.com/acme/shop/internal/orders.Map[...]",
+			"orders.go", "orders.Map[...]",
+		},
+	}
+
+	for name, tt := range tests {
+		file, function := tt.file, tt.function
+		tt.format.apply(&file, &function)
+
+		assert.Equal(t, tt.wantFile, file, name)
+		assert.Equal(t, tt.wantFunc, function, name)
+	}
+}
+
+func TestFunctionPackage(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "github.com/blendle/zapdriver", functionPackage("github.com/blendle/zapdriver.(*core).Write"))
+	assert.Equal(t, "main", functionPackage("main.main"))
+	assert.Equal(t, "github.com/acme/shop", functionPackage("github.com/acme/shop.Map[go.shape.*github.com/acme/shop.Order]"))
+}
+
+func TestSourceLocationFromCaller(t *testing.T) {
+	t.Parallel()
+
+	// sourceOfCaller is a wrapper, which reports the location of its caller.
+	sourceOfCaller := func() *source {
+		return SourceLocationFromCaller(1).Interface.(*source)
+	}
+
+	want, got := newSource(runtime.Caller(0)), sourceOfCaller()
+
+	assert.Contains(t, got.File, "zapdriver/source_test.go")
+	assert.Equal(t, want.Line, got.Line)
+	assert.Contains(t, got.Function, "zapdriver.TestSourceLocationFromCaller")
+}
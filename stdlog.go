@@ -0,0 +1,181 @@
+package zapdriver
+
+import (
+	"bytes"
+	"log"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// stdLogCallerSkip is the number of stack frames between the code calling the
+// standard library logger and `stdLogWriter.Write`:
+//
+//	caller -> log.Printf -> (*log.Logger).output -> stdLogWriter.Write
+const stdLogCallerSkip = 3
+
+// stdLogSeverities maps the severity names conventionally used by third-party
+// libraries to Zap log levels.
+//
+// Anything more severe than an error is logged at ErrorLevel, so a line written
+// by a third-party library can never trigger a panic or exit the process.
+var stdLogSeverities = map[string]zapcore.Level{
+	"trace":     zapcore.DebugLevel,
+	"debug":     zapcore.DebugLevel,
+	"info":      zapcore.InfoLevel,
+	"notice":    zapcore.InfoLevel,
+	"warn":      zapcore.WarnLevel,
+	"warning":   zapcore.WarnLevel,
+	"err":       zapcore.ErrorLevel,
+	"error":     zapcore.ErrorLevel,
+	"crit":      zapcore.ErrorLevel,
+	"critical":  zapcore.ErrorLevel,
+	"alert":     zapcore.ErrorLevel,
+	"emerg":     zapcore.ErrorLevel,
+	"emergency": zapcore.ErrorLevel,
+	"fatal":     zapcore.ErrorLevel,
+	"panic":     zapcore.ErrorLevel,
+}
+
+// stdLogWriter is an io.Writer that writes every line it receives from a
+// standard library logger to a Zap logger.
+type stdLogWriter struct {
+	logger *zap.Logger
+
+	// level is the level at which lines are logged when their severity is
+	// unknown.
+	level zapcore.Level
+
+	// parseSeverity enables parsing of conventional severity prefixes such as
+	// `[ERROR]`, `WARN:` or `level=error`.
+	parseSeverity bool
+}
+
+// StdLogLevel sets the level at which redirected standard library log lines
+// are written when no severity could be parsed from them. Defaults to
+// InfoLevel.
+func StdLogLevel(level zapcore.Level) func(*stdLogWriter) {
+	return func(w *stdLogWriter) {
+		w.level = level
+	}
+}
+
+// StdLogParseSeverity enables parsing conventional severity prefixes (such as
+// `[ERROR]`, `WARN:` or `level=error`) from redirected standard library log
+// lines, and logging those lines at the matching level.
+func StdLogParseSeverity(parse bool) func(*stdLogWriter) {
+	return func(w *stdLogWriter) {
+		w.parseSeverity = parse
+	}
+}
+
+// NewStdLog returns a `*log.Logger` which writes to the supplied Zap logger.
+func NewStdLog(l *zap.Logger, options ...func(*stdLogWriter)) *log.Logger {
+	return log.New(newStdLogWriter(l, options...), "", 0)
+}
+
+// RedirectStdLog redirects output from the standard library's package-global
+// logger to the supplied logger. It returns a function to restore the original
+// prefix, flags and output of the standard library's logger.
+func RedirectStdLog(l *zap.Logger, options ...func(*stdLogWriter)) func() {
+	flags, prefix, out := log.Flags(), log.Prefix(), log.Writer()
+
+	log.SetFlags(0)
+	log.SetPrefix("")
+	log.SetOutput(newStdLogWriter(l, options...))
+
+	return func() {
+		log.SetFlags(flags)
+		log.SetPrefix(prefix)
+		log.SetOutput(out)
+	}
+}
+
+// RedirectStdLogger is the same as RedirectStdLog, but redirects the output of
+// the passed in `*log.Logger` instead of the package-global logger.
+func RedirectStdLogger(std *log.Logger, l *zap.Logger, options ...func(*stdLogWriter)) func() {
+	flags, prefix, out := std.Flags(), std.Prefix(), std.Writer()
+
+	std.SetFlags(0)
+	std.SetPrefix("")
+	std.SetOutput(newStdLogWriter(l, options...))
+
+	return func() {
+		std.SetFlags(flags)
+		std.SetPrefix(prefix)
+		std.SetOutput(out)
+	}
+}
+
+func newStdLogWriter(l *zap.Logger, options ...func(*stdLogWriter)) *stdLogWriter {
+	w := &stdLogWriter{
+		logger: l.WithOptions(zap.AddCallerSkip(stdLogCallerSkip)),
+		level:  zapcore.InfoLevel,
+	}
+	for _, option := range options {
+		option(w)
+	}
+
+	return w
+}
+
+// Write implements io.Writer. The standard library logger calls Write exactly
+// once for every line it logs.
+func (w *stdLogWriter) Write(b []byte) (int, error) {
+	msg := string(bytes.TrimSuffix(b, []byte("\n")))
+
+	level := w.level
+	if w.parseSeverity {
+		level, msg = parseStdLogSeverity(msg, level)
+	}
+
+	if ce := w.logger.Check(level, msg); ce != nil {
+		ce.Write()
+	}
+
+	return len(b), nil
+}
+
+// parseStdLogSeverity parses the severity of a log line, based on the following
+// conventions:
+//
+//	[ERROR] message
+//	ERROR: message
+//	time=... level=error msg=...
+//
+// The severity prefix is removed from the returned message for the first two
+// conventions. Logfmt lines are returned as-is. If no known severity is found,
+// the passed in default level is returned.
+func parseStdLogSeverity(msg string, def zapcore.Level) (zapcore.Level, string) {
+	if strings.HasPrefix(msg, "[") {
+		if i := strings.Index(msg, "]"); i > 0 {
+			if level, ok := stdLogSeverities[strings.ToLower(msg[1:i])]; ok {
+				return level, trimStdLogSeparator(msg[i+1:])
+			}
+		}
+	}
+
+	if i := strings.Index(msg, ":"); i > 0 && !strings.ContainsAny(msg[:i], " \t") {
+		if level, ok := stdLogSeverities[strings.ToLower(msg[:i])]; ok {
+			return level, trimStdLogSeparator(msg[i+1:])
+		}
+	}
+
+	for _, pair := range strings.Fields(msg) {
+		if !strings.HasPrefix(strings.ToLower(pair), "level=") {
+			continue
+		}
+
+		value := strings.Trim(pair[len("level="):], `"'`)
+		if level, ok := stdLogSeverities[strings.ToLower(value)]; ok {
+			return level, msg
+		}
+	}
+
+	return def, msg
+}
+
+func trimStdLogSeparator(msg string) string {
+	return strings.TrimLeft(strings.TrimPrefix(msg, ":"), " \t")
+}
@@ -0,0 +1,112 @@
+package zapdriver
+
+import (
+	"bytes"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestParseStdLogSeverity(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		msg       string
+		wantLevel zapcore.Level
+		wantMsg   string
+	}{
+		"no severity":      {"hello world", zapcore.InfoLevel, "hello world"},
+		"brackets":         {"[ERROR] hello world", zapcore.ErrorLevel, "hello world"},
+		"brackets colon":   {"[warn]: hello world", zapcore.WarnLevel, "hello world"},
+		"brackets unknown": {"[client] hello world", zapcore.InfoLevel, "[client] hello world"},
+		"colon":            {"WARN: hello world", zapcore.WarnLevel, "hello world"},
+		"colon debug":      {"debug:hello world", zapcore.DebugLevel, "hello world"},
+		"colon unknown":    {"retrying: hello world", zapcore.InfoLevel, "retrying: hello world"},
+		"colon in message": {"connection refused: error", zapcore.InfoLevel, "connection refused: error"},
+		"logfmt":           {"ts=1 level=error msg=hello", zapcore.ErrorLevel, "ts=1 level=error msg=hello"},
+		"logfmt quoted":    {`level="warning" msg=hello`, zapcore.WarnLevel, `level="warning" msg=hello`},
+		"fatal":            {"[FATAL] hello world", zapcore.ErrorLevel, "hello world"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			level, msg := parseStdLogSeverity(tt.msg, zapcore.InfoLevel)
+
+			assert.Equal(t, tt.wantLevel, level)
+			assert.Equal(t, tt.wantMsg, msg)
+		})
+	}
+}
+
+func TestNewStdLog(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	std := NewStdLog(zap.New(core, zap.AddCaller()), StdLogParseSeverity(true))
+
+	std.Printf("[ERROR] hello %s", "world")
+
+	require.Equal(t, 1, logs.Len())
+	entry := logs.All()[0]
+
+	assert.Equal(t, zapcore.ErrorLevel, entry.Level)
+	assert.Equal(t, "hello world", entry.Message)
+	assert.Contains(t, entry.Caller.File, "zapdriver/stdlog_test.go")
+}
+
+func TestNewStdLog_WithoutParsing(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	std := NewStdLog(zap.New(core), StdLogLevel(zapcore.WarnLevel))
+
+	std.Print("[ERROR] hello world")
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, zapcore.WarnLevel, logs.All()[0].Level)
+	assert.Equal(t, "[ERROR] hello world", logs.All()[0].Message)
+}
+
+func TestRedirectStdLog(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	log.SetFlags(log.Lshortfile)
+	log.SetPrefix("prefix ")
+
+	restore := RedirectStdLog(zap.New(core, zap.AddCaller()), StdLogParseSeverity(true))
+	log.Println("level=warn msg=hello")
+	restore()
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, zapcore.WarnLevel, logs.All()[0].Level)
+	assert.Contains(t, logs.All()[0].Caller.File, "zapdriver/stdlog_test.go")
+	assert.Empty(t, buf.String())
+
+	assert.Equal(t, log.Lshortfile, log.Flags())
+	assert.Equal(t, "prefix ", log.Prefix())
+
+	log.Print("hello universe")
+	assert.Contains(t, buf.String(), "hello universe")
+	assert.Equal(t, 1, logs.Len())
+}
+
+func TestRedirectStdLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+
+	buf := &bytes.Buffer{}
+	std := log.New(buf, "prefix ", log.LstdFlags)
+
+	restore := RedirectStdLogger(std, zap.New(core))
+	std.Print("hello world")
+	restore()
+	std.Print("hello universe")
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "hello world", logs.All()[0].Message)
+	assert.Contains(t, buf.String(), "prefix ")
+	assert.Contains(t, buf.String(), "hello universe")
+	assert.NotContains(t, buf.String(), "hello world")
+}
@@ -0,0 +1,70 @@
+package zapdriver
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// taskProducer is the operation producer of Cloud Tasks operations.
+const taskProducer = "cloudtasks.googleapis.com"
+
+// headerLabel is a label that is added for the first of the headers that is
+// set.
+type headerLabel struct {
+	label   string
+	headers []string
+}
+
+// taskHeaders maps the headers set by Cloud Tasks to the labels they are added
+// as. The App Engine headers are used for App Engine targets.
+//
+// see: https://cloud.google.com/tasks/docs/creating-http-target-tasks#handler
+// see: https://cloud.google.com/tasks/docs/creating-appengine-handlers#reading_request_headers
+var taskHeaders = []headerLabel{
+	{"cloudtasks.queue", []string{"X-CloudTasks-QueueName", "X-AppEngine-QueueName"}},
+	{"cloudtasks.task", []string{"X-CloudTasks-TaskName", "X-AppEngine-TaskName"}},
+	{"cloudtasks.retry_count", []string{"X-CloudTasks-TaskRetryCount", "X-AppEngine-TaskRetryCount"}},
+	{"cloudtasks.execution_count", []string{"X-CloudTasks-TaskExecutionCount", "X-AppEngine-TaskExecutionCount"}},
+	{"cloudtasks.eta", []string{"X-CloudTasks-TaskETA", "X-AppEngine-TaskETA"}},
+	{"cloudtasks.previous_response", []string{"X-CloudTasks-TaskPreviousResponse", "X-AppEngine-TaskPreviousResponse"}},
+	{"cloudtasks.retry_reason", []string{"X-CloudTasks-TaskRetryReason", "X-AppEngine-TaskRetryReason"}},
+}
+
+// schedulerHeaders maps the headers set by Cloud Scheduler to the labels they
+// are added as.
+//
+// see: https://cloud.google.com/scheduler/docs/reference/rest/v1/projects.locations.jobs#HttpTarget
+var schedulerHeaders = []headerLabel{
+	{"cloudscheduler.job", []string{"X-CloudScheduler-JobName"}},
+	{"cloudscheduler.schedule_time", []string{"X-CloudScheduler-ScheduleTime"}},
+}
+
+// TaskFields returns labels for the headers set by Cloud Tasks and Cloud
+// Scheduler on the requests they make, such as the queue, task and retry count.
+// It returns nil for requests without these headers.
+//
+// For Cloud Tasks requests, an `Operation()` is added with the queue and task
+// name as ID, so all attempts of a task are shown as one operation.
+func TaskFields(r *http.Request) []zap.Field {
+	var fields []zap.Field
+	values := map[string]string{}
+
+	for _, list := range [][]headerLabel{taskHeaders, schedulerHeaders} {
+		for _, h := range list {
+			for _, header := range h.headers {
+				if v := r.Header.Get(header); v != "" {
+					fields = append(fields, Label(h.label, v))
+					values[h.label] = v
+					break
+				}
+			}
+		}
+	}
+
+	if task := values["cloudtasks.task"]; task != "" {
+		fields = append(fields, OperationCont(values["cloudtasks.queue"]+"/"+task, taskProducer))
+	}
+
+	return fields
+}
@@ -0,0 +1,88 @@
+package zapdriver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestTaskFields(t *testing.T) {
+	t.Parallel()
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	assert.Nil(t, TaskFields(r))
+
+	r.Header.Set("X-CloudTasks-QueueName", "my-queue")
+	r.Header.Set("X-CloudTasks-TaskName", "1234")
+	r.Header.Set("X-CloudTasks-TaskRetryCount", "2")
+	r.Header.Set("X-CloudTasks-TaskExecutionCount", "1")
+	r.Header.Set("X-CloudTasks-TaskETA", "1559383200.5")
+
+	assert.Equal(t, []zap.Field{
+		Label("cloudtasks.queue", "my-queue"),
+		Label("cloudtasks.task", "1234"),
+		Label("cloudtasks.retry_count", "2"),
+		Label("cloudtasks.execution_count", "1"),
+		Label("cloudtasks.eta", "1559383200.5"),
+		OperationCont("my-queue/1234", "cloudtasks.googleapis.com"),
+	}, TaskFields(r))
+}
+
+func TestTaskFields_AppEngine(t *testing.T) {
+	t.Parallel()
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("X-AppEngine-QueueName", "default")
+	r.Header.Set("X-AppEngine-TaskName", "5678")
+	r.Header.Set("X-AppEngine-TaskRetryCount", "0")
+
+	assert.Equal(t, []zap.Field{
+		Label("cloudtasks.queue", "default"),
+		Label("cloudtasks.task", "5678"),
+		Label("cloudtasks.retry_count", "0"),
+		OperationCont("default/5678", "cloudtasks.googleapis.com"),
+	}, TaskFields(r))
+}
+
+func TestTaskFields_Scheduler(t *testing.T) {
+	t.Parallel()
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("X-CloudScheduler", "true")
+	r.Header.Set("X-CloudScheduler-JobName", "nightly")
+	r.Header.Set("X-CloudScheduler-ScheduleTime", "2019-06-01T03:00:00Z")
+
+	assert.Equal(t, []zap.Field{
+		Label("cloudscheduler.job", "nightly"),
+		Label("cloudscheduler.schedule_time", "2019-06-01T03:00:00Z"),
+	}, TaskFields(r))
+}
+
+func TestEventHandler_TaskFields(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore())
+
+	handler := EventHandler(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		LoggerFromContext(r.Context()).Info("handling")
+	}), EventTaskFields(true))
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}"))
+	r.Header.Set("X-CloudTasks-QueueName", "my-queue")
+	r.Header.Set("X-CloudTasks-TaskName", "1234")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	require.Equal(t, 3, logs.Len())
+	for _, entry := range logs.All() {
+		context := entry.ContextMap()
+
+		assert.Equal(t, map[string]interface{}{"cloudtasks.queue": "my-queue", "cloudtasks.task": "1234"}, context[labelsKey])
+		assert.Equal(t, "my-queue/1234", context[operationKey].(map[string]interface{})["id"])
+	}
+}
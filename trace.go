@@ -2,6 +2,9 @@ package zapdriver
 
 import (
 	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
 
 	"go.uber.org/zap"
 )
@@ -22,3 +25,58 @@ func TraceContext(trace string, spanId string, sampled bool, projectName string)
 		zap.Bool(traceSampledKey, sampled),
 	}
 }
+
+// TraceContextFromRequest returns the TraceContext fields for the trace of an
+// incoming request, based on the `X-Cloud-Trace-Context` header set by Google
+// Cloud load balancers and serverless platforms, or the W3C `traceparent`
+// header. It returns nil if the request has neither.
+//
+// If projectID is empty, use the `ProjectID()` core option to complete the
+// trace resource name.
+//
+// see: https://cloud.google.com/trace/docs/setup#force-trace
+// see: https://www.w3.org/TR/trace-context/#traceparent-header
+func TraceContextFromRequest(r *http.Request, projectID string) []zap.Field {
+	if h := r.Header.Get("X-Cloud-Trace-Context"); h != "" {
+		// TRACE_ID/SPAN_ID;o=TRACE_TRUE, with a decimal span ID.
+		h, options := splitOnce(h, ";")
+		trace, span := splitOnce(h, "/")
+
+		if trace != "" {
+			if id, err := strconv.ParseUint(span, 10, 64); err == nil {
+				span = fmt.Sprintf("%016x", id)
+			} else {
+				span = ""
+			}
+
+			return TraceContext(trace, span, options == "o=1", projectID)
+		}
+	}
+
+	return traceparentContext(r.Header.Get("traceparent"), projectID)
+}
+
+// traceparentContext returns the TraceContext fields for a W3C `traceparent`
+// header value, or nil if it is invalid.
+func traceparentContext(traceparent, projectID string) []zap.Field {
+	// VERSION-TRACE_ID-SPAN_ID-FLAGS, in hexadecimal.
+	parts := strings.Split(traceparent, "-")
+	if len(parts) < 4 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return nil
+	}
+
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return nil
+	}
+
+	return TraceContext(parts[1], parts[2], flags&1 == 1, projectID)
+}
+
+func splitOnce(s, sep string) (string, string) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):]
+	}
+
+	return s, ""
+}
@@ -1,6 +1,8 @@
 package zapdriver
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -17,3 +19,50 @@ func TestTraceContext(t *testing.T) {
 		zap.Bool(traceSampledKey, true),
 	})
 }
+
+func TestTraceContextFromRequest(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		headers map[string]string
+		want    []zap.Field
+	}{
+		"none": {map[string]string{}, nil},
+		"cloud trace": {
+			map[string]string{"X-Cloud-Trace-Context": "105445aa7843bc8bf206b12000100000/1;o=1"},
+			TraceContext("105445aa7843bc8bf206b12000100000", "0000000000000001", true, "my-project"),
+		},
+		"cloud trace not sampled": {
+			map[string]string{"X-Cloud-Trace-Context": "105445aa7843bc8bf206b12000100000/255;o=0"},
+			TraceContext("105445aa7843bc8bf206b12000100000", "00000000000000ff", false, "my-project"),
+		},
+		"cloud trace without span": {
+			map[string]string{"X-Cloud-Trace-Context": "105445aa7843bc8bf206b12000100000"},
+			TraceContext("105445aa7843bc8bf206b12000100000", "", false, "my-project"),
+		},
+		"traceparent": {
+			map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			TraceContext("4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", true, "my-project"),
+		},
+		"invalid traceparent": {
+			map[string]string{"traceparent": "00-4bf92f35-00f067aa0ba902b7-01"},
+			nil,
+		},
+		"cloud trace takes precedence": {
+			map[string]string{
+				"X-Cloud-Trace-Context": "105445aa7843bc8bf206b12000100000/1;o=1",
+				"traceparent":           "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			},
+			TraceContext("105445aa7843bc8bf206b12000100000", "0000000000000001", true, "my-project"),
+		},
+	}
+
+	for name, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for k, v := range tt.headers {
+			r.Header.Set(k, v)
+		}
+
+		assert.Equal(t, tt.want, TraceContextFromRequest(r, "my-project"), name)
+	}
+}
@@ -0,0 +1,23 @@
+package zapdrivertest
+
+import (
+	"github.com/blendle/zapdriver"
+)
+
+// Entry is a single recorded log entry.
+type Entry = zapdriver.LogEntry
+
+// SourceLocation is the source code location of an entry.
+type SourceLocation = zapdriver.LogEntrySourceLocation
+
+// Operation is the operation an entry belongs to.
+type Operation = zapdriver.LogEntryOperation
+
+// ServiceContext is the service that produced an entry.
+type ServiceContext = zapdriver.LogEntryServiceContext
+
+// ErrorContext is the context of an entry reported to Error Reporting.
+type ErrorContext = zapdriver.LogEntryErrorContext
+
+// ReportLocation is the source code location of a reported error.
+type ReportLocation = zapdriver.LogEntryReportLocation
@@ -0,0 +1,59 @@
+package zapdrivertest
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/blendle/zapdriver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntry_UnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	line := `{
+		"severity": "ERROR",
+		"timestamp": "2018-04-09T12:43:12.000678359Z",
+		"message": "hello world",
+		"logging.googleapis.com/labels": {"one": "1"},
+		"logging.googleapis.com/sourceLocation": {"file": "main.go", "line": "12", "function": "main.main"},
+		"httpRequest": {"requestMethod": "GET", "status": 200},
+		"logging.googleapis.com/operation": {"id": "id", "producer": "producer", "first": true, "last": false},
+		"logging.googleapis.com/trace": "projects/my-project/traces/abc",
+		"logging.googleapis.com/spanId": "0",
+		"logging.googleapis.com/trace_sampled": true,
+		"logging.googleapis.com/insertId": "def",
+		"serviceContext": {"service": "my service"},
+		"context": {"reportLocation": {"filePath": "main.go", "lineNumber": "12", "functionName": "main.main"}},
+		"hello": "universe",
+		"count": 3
+	}`
+
+	var got Entry
+	require.NoError(t, json.Unmarshal([]byte(line), &got))
+
+	want := Entry{
+		Severity:       "ERROR",
+		Timestamp:      time.Date(2018, 4, 9, 12, 43, 12, 678359, time.UTC),
+		Message:        "hello world",
+		Labels:         map[string]string{"one": "1"},
+		SourceLocation: &SourceLocation{File: "main.go", Line: "12", Function: "main.main"},
+		HTTPRequest:    &zapdriver.HTTPPayload{RequestMethod: "GET", Status: 200},
+		Operation:      &Operation{ID: "id", Producer: "producer", First: true},
+		Trace:          "projects/my-project/traces/abc",
+		SpanID:         "0",
+		TraceSampled:   true,
+		InsertID:       "def",
+		ServiceContext: &ServiceContext{Service: "my service"},
+		Context: &ErrorContext{
+			ReportLocation: &ReportLocation{FilePath: "main.go", LineNumber: "12", FunctionName: "main.main"},
+		},
+		Payload: map[string]interface{}{"hello": "universe", "count": float64(3)},
+	}
+
+	assert.Equal(t, want.Timestamp.UnixNano(), got.Timestamp.UnixNano())
+	got.Timestamp = want.Timestamp
+	assert.Equal(t, want, got)
+}
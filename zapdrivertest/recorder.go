@@ -0,0 +1,248 @@
+// Package zapdrivertest provides a Zapdriver logger that records all log
+// entries in memory, and helpers to query and assert on the recorded entries
+// in tests.
+package zapdrivertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/blendle/zapdriver"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// New returns a logger with the default Zapdriver core, that writes DebugLevel
+// and above logs to the returned Recorder.
+func New(options ...zap.Option) (*zap.Logger, *Recorder) {
+	return NewWithCore(zapdriver.WrapCore(), options...)
+}
+
+// NewWithCore is the same as New, but accepts a custom configured core.
+func NewWithCore(core zap.Option, options ...zap.Option) (*zap.Logger, *Recorder) {
+	recorder := &Recorder{}
+	options = append(options, zap.AddCaller(), core)
+
+	encoder := zapcore.NewJSONEncoder(zapdriver.NewProductionEncoderConfig())
+	logger := zap.New(zapcore.NewCore(encoder, recorder, zapcore.DebugLevel), options...)
+
+	return logger, recorder
+}
+
+// Recorder is a zapcore.WriteSyncer that decodes and records all log entries
+// written to it.
+type Recorder struct {
+	mutex   sync.RWMutex
+	entries []Entry
+}
+
+// Write implements io.Writer.
+func (r *Recorder) Write(p []byte) (int, error) {
+	var entries []Entry
+	for _, line := range bytes.Split(bytes.TrimSpace(p), []byte("\n")) {
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return 0, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	r.mutex.Lock()
+	r.entries = append(r.entries, entries...)
+	r.mutex.Unlock()
+
+	return len(p), nil
+}
+
+// Sync implements zapcore.WriteSyncer.
+func (r *Recorder) Sync() error {
+	return nil
+}
+
+// All returns all recorded entries.
+func (r *Recorder) All() []Entry {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return append([]Entry(nil), r.entries...)
+}
+
+// Len returns the number of recorded entries.
+func (r *Recorder) Len() int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return len(r.entries)
+}
+
+// Reset removes all recorded entries.
+func (r *Recorder) Reset() {
+	r.mutex.Lock()
+	r.entries = nil
+	r.mutex.Unlock()
+}
+
+// Filter returns all recorded entries that match all of the matchers.
+func (r *Recorder) Filter(matchers ...Matcher) []Entry {
+	var out []Entry
+	for _, entry := range r.All() {
+		if matchAll(entry, matchers) {
+			out = append(out, entry)
+		}
+	}
+
+	return out
+}
+
+// AssertLogged fails the test if none of the recorded entries match all of the
+// matchers. It returns the first matching entry.
+func (r *Recorder) AssertLogged(t testing.TB, matchers ...Matcher) Entry {
+	t.Helper()
+
+	entries := r.Filter(matchers...)
+	if len(entries) == 0 {
+		t.Errorf("expected an entry with %s, got none in:\n%s", describe(matchers), r.dump())
+		return Entry{}
+	}
+
+	return entries[0]
+}
+
+// AssertNotLogged fails the test if any of the recorded entries match all of
+// the matchers.
+func (r *Recorder) AssertNotLogged(t testing.TB, matchers ...Matcher) {
+	t.Helper()
+
+	if n := len(r.Filter(matchers...)); n > 0 {
+		t.Errorf("expected no entries with %s, got %d in:\n%s", describe(matchers), n, r.dump())
+	}
+}
+
+// AssertCount fails the test if the number of recorded entries that match all
+// of the matchers is not n.
+func (r *Recorder) AssertCount(t testing.TB, n int, matchers ...Matcher) {
+	t.Helper()
+
+	if got := len(r.Filter(matchers...)); got != n {
+		t.Errorf("expected %d entries with %s, got %d in:\n%s", n, describe(matchers), got, r.dump())
+	}
+}
+
+// dump returns a human readable list of all recorded entries.
+func (r *Recorder) dump() string {
+	entries := r.All()
+	if len(entries) == 0 {
+		return "  (no entries)"
+	}
+
+	lines := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		lines = append(lines, "  "+entry.Severity+" "+entry.Message)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Matcher matches recorded entries.
+type Matcher struct {
+	description string
+	match       func(Entry) bool
+}
+
+// Match returns a custom Matcher. The description is used in the failure
+// messages of assertions.
+func Match(description string, match func(Entry) bool) Matcher {
+	return Matcher{description: description, match: match}
+}
+
+// Severity matches entries with the given Stackdriver severity, such as
+// "ERROR".
+func Severity(severity string) Matcher {
+	return Match("severity "+severity, func(e Entry) bool {
+		return e.Severity == severity
+	})
+}
+
+// Message matches entries with the given message.
+func Message(msg string) Matcher {
+	return Match("message "+strconv.Quote(msg), func(e Entry) bool {
+		return e.Message == msg
+	})
+}
+
+// MessageContains matches entries with a message containing substr.
+func MessageContains(substr string) Matcher {
+	return Match("message containing "+strconv.Quote(substr), func(e Entry) bool {
+		return strings.Contains(e.Message, substr)
+	})
+}
+
+// Label matches entries with the given label.
+func Label(key, value string) Matcher {
+	return Match("label "+key+"="+value, func(e Entry) bool {
+		v, ok := e.Labels[key]
+		return ok && v == value
+	})
+}
+
+// HasLabel matches entries with a label with the given key.
+func HasLabel(key string) Matcher {
+	return Match("label "+key, func(e Entry) bool {
+		_, ok := e.Labels[key]
+		return ok
+	})
+}
+
+// Trace matches entries with the given trace, such as
+// "projects/my-project/traces/105445aa7843bc8bf206b120001000".
+func Trace(trace string) Matcher {
+	return Match("trace "+trace, func(e Entry) bool {
+		return e.Trace == trace
+	})
+}
+
+// Field matches entries with a payload field with the given key and value.
+// Values are compared after decoding from JSON, so numbers are float64.
+func Field(key string, value interface{}) Matcher {
+	return Match("field "+key, func(e Entry) bool {
+		v, ok := e.Payload[key]
+		return ok && reflect.DeepEqual(v, value)
+	})
+}
+
+// ErrorReported matches entries that are reported to Error Reporting, meaning
+// they have both a service context and a report location.
+func ErrorReported() Matcher {
+	return Match("reported to Error Reporting", func(e Entry) bool {
+		return e.ServiceContext != nil && e.Context != nil && e.Context.ReportLocation != nil
+	})
+}
+
+func matchAll(entry Entry, matchers []Matcher) bool {
+	for _, m := range matchers {
+		if !m.match(entry) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func describe(matchers []Matcher) string {
+	if len(matchers) == 0 {
+		return "anything"
+	}
+
+	descriptions := make([]string, 0, len(matchers))
+	for _, m := range matchers {
+		descriptions = append(descriptions, m.description)
+	}
+
+	return strings.Join(descriptions, ", ")
+}
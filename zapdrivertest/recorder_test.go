@@ -0,0 +1,100 @@
+package zapdrivertest_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/blendle/zapdriver"
+	"github.com/blendle/zapdriver/zapdrivertest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// spyTB records failures, instead of failing the test.
+type spyTB struct {
+	testing.TB
+
+	errors []string
+}
+
+func (s *spyTB) Helper() {}
+
+func (s *spyTB) Errorf(format string, args ...interface{}) {
+	s.errors = append(s.errors, fmt.Sprintf(format, args...))
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	logger, recorder := zapdrivertest.New()
+	logger.With(zapdriver.Label("one", "1")).Info("hello world", zapdriver.Label("two", "2"))
+
+	require.Equal(t, 1, recorder.Len())
+	entry := recorder.All()[0]
+
+	assert.Equal(t, "INFO", entry.Severity)
+	assert.Equal(t, "hello world", entry.Message)
+	assert.Equal(t, map[string]string{"one": "1", "two": "2"}, entry.Labels)
+	require.NotNil(t, entry.SourceLocation)
+	assert.Contains(t, entry.SourceLocation.File, "zapdrivertest/recorder_test.go")
+	assert.False(t, entry.Timestamp.IsZero())
+}
+
+func TestNewWithCore(t *testing.T) {
+	t.Parallel()
+
+	logger, recorder := zapdrivertest.NewWithCore(zapdriver.WrapCore(
+		zapdriver.ReportAllErrors(true),
+		zapdriver.ServiceName("my service"),
+	))
+	logger.Error("failed", zap.Error(errors.New("boom")), zapdriver.Label("tenant", "acme"))
+
+	entry := recorder.AssertLogged(t, zapdrivertest.Severity("ERROR"), zapdrivertest.Label("tenant", "acme"), zapdrivertest.ErrorReported())
+
+	assert.Equal(t, "my service", entry.ServiceContext.Service)
+	assert.Equal(t, "boom", entry.Payload["error"])
+}
+
+func TestRecorder_Filter(t *testing.T) {
+	t.Parallel()
+
+	logger, recorder := zapdrivertest.New()
+	logger.Debug("one")
+	logger.Info("two", zap.Int("count", 2))
+	logger.Warn("three", zapdriver.TraceContext("abc", "0", true, "my-project")...)
+
+	assert.Len(t, recorder.Filter(), 3)
+	assert.Len(t, recorder.Filter(zapdrivertest.Severity("DEBUG")), 1)
+	assert.Len(t, recorder.Filter(zapdrivertest.MessageContains("t")), 2)
+	assert.Len(t, recorder.Filter(zapdrivertest.Field("count", float64(2))), 1)
+	assert.Len(t, recorder.Filter(zapdrivertest.Trace("projects/my-project/traces/abc")), 1)
+	assert.Len(t, recorder.Filter(zapdrivertest.Message("two"), zapdrivertest.Severity("DEBUG")), 0)
+
+	recorder.Reset()
+	assert.Equal(t, 0, recorder.Len())
+}
+
+func TestRecorder_Assertions(t *testing.T) {
+	t.Parallel()
+
+	logger, recorder := zapdrivertest.New()
+	logger.Error("failed", zapdriver.Label("tenant", "acme"))
+
+	spy := &spyTB{}
+	recorder.AssertLogged(spy, zapdrivertest.Severity("ERROR"), zapdrivertest.HasLabel("tenant"))
+	recorder.AssertNotLogged(spy, zapdrivertest.Severity("WARNING"))
+	recorder.AssertCount(spy, 1, zapdrivertest.Message("failed"))
+	assert.Empty(t, spy.errors)
+
+	recorder.AssertLogged(spy, zapdrivertest.Severity("ERROR"), zapdrivertest.ErrorReported())
+	recorder.AssertNotLogged(spy, zapdrivertest.Label("tenant", "acme"))
+	recorder.AssertCount(spy, 2, zapdrivertest.Message("failed"))
+
+	require.Len(t, spy.errors, 3)
+	assert.Contains(t, spy.errors[0], "severity ERROR, reported to Error Reporting")
+	assert.Contains(t, spy.errors[0], "ERROR failed")
+	assert.Contains(t, spy.errors[1], "label tenant=acme")
+	assert.Contains(t, spy.errors[2], "expected 2 entries")
+}
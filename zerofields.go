@@ -0,0 +1,73 @@
+package zapdriver
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// zeroFieldsMarshaler is implemented by the special purpose fields that omit
+// their unset fields, such as a zero status or an empty latency.
+type zeroFieldsMarshaler interface {
+	marshalLogObject(enc zapcore.ObjectEncoder, omitZero bool) error
+}
+
+// zeroFieldsObject marshals a zeroFieldsMarshaler with or without its unset
+// fields.
+type zeroFieldsObject struct {
+	marshaler zeroFieldsMarshaler
+	omitZero  bool
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+func (o zeroFieldsObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return o.marshaler.marshalLogObject(enc, o.omitZero)
+}
+
+// zeroFieldsEncoder adds values to the wrapped encoder, skipping zero values
+// if omitZero is set.
+type zeroFieldsEncoder struct {
+	enc      zapcore.ObjectEncoder
+	omitZero bool
+}
+
+func (e zeroFieldsEncoder) AddString(key, value string) {
+	if !e.omitZero || value != "" {
+		e.enc.AddString(key, value)
+	}
+}
+
+func (e zeroFieldsEncoder) AddInt(key string, value int) {
+	if !e.omitZero || value != 0 {
+		e.enc.AddInt(key, value)
+	}
+}
+
+func (e zeroFieldsEncoder) AddBool(key string, value bool) {
+	if !e.omitZero || value {
+		e.enc.AddBool(key, value)
+	}
+}
+
+// withZeroFields replaces the special purpose fields in fields by ones that
+// keep their unset fields.
+func withZeroFields(fields []zapcore.Field) []zapcore.Field {
+	var out []zapcore.Field
+
+	for i := range fields {
+		m, ok := fields[i].Interface.(zeroFieldsMarshaler)
+		if !ok || fields[i].Type != zapcore.ObjectMarshalerType {
+			continue
+		}
+
+		if out == nil {
+			out = append([]zapcore.Field(nil), fields...)
+		}
+		out[i] = zap.Object(fields[i].Key, zeroFieldsObject{m, false})
+	}
+
+	if out == nil {
+		return fields
+	}
+
+	return out
+}